@@ -0,0 +1,384 @@
+package cupcake
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Converter converts string from request to value of a specific type
+type Converter func(string) (interface{}, error)
+
+// BindError describes a field failed to bind
+type BindError struct {
+	Field  string `json:"field"`
+	Source string `json:"source"` // uri, query, header, form, file or body
+	Value  string `json:"value,omitempty"`
+	// Reason of the failure for clients
+	Message string `json:"message"`
+	Err     error  `json:"-"`
+}
+
+func newBindError(field string, source string, value string, err error) *BindError {
+	return &BindError{Field: field, Source: source, Value: value, Message: err.Error(), Err: err}
+}
+
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("failed to bind %s: %s", e.Source, e.Err)
+	}
+	return fmt.Sprintf("failed to bind %s '%s' with value '%s': %s", e.Source, e.Field, e.Value, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// BindErrors contains all errors occurred when binding a request
+type BindErrors []*BindError
+
+func (errs BindErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+var (
+	converterMu sync.RWMutex
+	converters  = map[reflect.Type]Converter{
+		reflect.TypeOf(time.Duration(0)): func(s string) (interface{}, error) {
+			return time.ParseDuration(s)
+		},
+		reflect.TypeOf(time.Time{}): func(s string) (interface{}, error) {
+			return parseFormTime(s)
+		},
+	}
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// RegisterConverter registers converter for the type of sample, which is
+// used when binding values to fields of this type:
+//
+//	cupcake.RegisterConverter(decimal.Decimal{}, func(s string) (interface{}, error) {
+//		return decimal.NewFromString(s)
+//	})
+func RegisterConverter(sample interface{}, converter Converter) {
+	converterMu.Lock()
+	defer converterMu.Unlock()
+	converters[reflect.TypeOf(sample)] = converter
+}
+
+func getConverter(typ reflect.Type) (Converter, bool) {
+	converterMu.RLock()
+	defer converterMu.RUnlock()
+	converter, ok := converters[typ]
+	return converter, ok
+}
+
+// Bind fills obj with body, path params, query and headers of the request.
+// Body is decoded according to its Content-Type, then fields with tags
+// uri:"id", query:"page" and header:"X-Token" are filled, nested structs
+// without these tags are filled recursively. All failures are returned as
+// BindErrors, and the filled obj is then checked by Validate
+func (r *Request) Bind(obj interface{}) error {
+	objVal := reflect.ValueOf(obj)
+	if objVal.Kind() != reflect.Ptr || objVal.IsNil() || objVal.Elem().Kind() != reflect.Struct {
+		return errors.New("given obj is not pointer to a struct")
+	}
+
+	errs := BindErrors{}
+	if r.hasBody() {
+		if err := r.Parse(obj); err != nil {
+			var bindErrs BindErrors
+			if errors.Is(err, ErrBodyTooLarge) {
+				return err
+			}
+			if errors.As(err, &bindErrs) {
+				errs = append(errs, bindErrs...)
+			} else {
+				errs = append(errs, newBindError("", "body", "", err))
+			}
+		}
+	}
+
+	query := r.req.URL.Query()
+	sources := []bindSource{
+		{"uri", func(key string) []string {
+			if val, ok := r.params[key]; ok {
+				return []string{val}
+			}
+			return nil
+		}},
+		{"query", func(key string) []string {
+			return query[key]
+		}},
+		{"header", func(key string) []string {
+			return r.req.Header.Values(key)
+		}},
+	}
+	errs = append(errs, bindTags(objVal.Elem(), sources, nil)...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return Validate(obj)
+}
+
+// bindSource provides values for fields tagged with its name
+type bindSource struct {
+	name   string
+	values func(key string) []string
+}
+
+// hasBody reports whether the request has body to parse, body of media
+// types without codec is left unread so handlers can stream it
+func (r *Request) hasBody() bool {
+	ct := r.req.Header.Get("Content-Type")
+	if strings.HasPrefix(ct, ApplicationForm) || strings.HasPrefix(ct, MultipartForm) {
+		return true
+	}
+	if _, ok := getCodec(ct); !ok && normalizeMediaType(ct) != "" {
+		return false
+	}
+	// Errors of reading body are returned when it is parsed
+	ok, err := r.peekBody()
+	return err != nil || ok
+}
+
+// bindTags fills fields tagged with names of sources, types already being
+// filled are skipped so that recursive types terminate
+func bindTags(objVal reflect.Value, sources []bindSource, visiting map[reflect.Type]bool) BindErrors {
+	errs := BindErrors{}
+	objType := objVal.Type()
+	if visiting == nil {
+		visiting = map[reflect.Type]bool{}
+	}
+	if visiting[objType] {
+		return errs
+	}
+	visiting[objType] = true
+	defer delete(visiting, objType)
+
+	for idx := 0; idx < objType.NumField(); idx++ {
+		field := objVal.Field(idx)
+		fieldType := objType.Field(idx)
+		if !field.CanSet() {
+			continue
+		}
+
+		tagged := false
+		for _, source := range sources {
+			key := strings.Split(fieldType.Tag.Get(source.name), ",")[0]
+			if key == "" || key == "-" {
+				continue
+			}
+			tagged = true
+			vals := source.values(key)
+			if err := setField(field, vals); err != nil {
+				errs = append(errs, newBindError(key, source.name, strings.Join(vals, ","), err))
+			}
+		}
+		if tagged || !isNestedStruct(fieldType.Type) {
+			continue
+		}
+		errs = append(errs, bindNested(field, func(nested reflect.Value) BindErrors {
+			return bindTags(nested, sources, visiting)
+		})...)
+	}
+	return errs
+}
+
+// bindNested fills nested struct by bind, nil pointer is allocated only if
+// it is used
+func bindNested(field reflect.Value, bind func(reflect.Value) BindErrors) BindErrors {
+	if field.Kind() != reflect.Ptr {
+		return bind(field)
+	}
+	nested := reflect.New(field.Type().Elem())
+	if !field.IsNil() {
+		nested = field
+	}
+	errs := bind(nested.Elem())
+	if field.IsNil() && !nested.Elem().IsZero() {
+		field.Set(nested)
+	}
+	return errs
+}
+
+// isNestedStruct reports whether the type is struct or pointer to struct
+// which should be filled field by field
+func isNestedStruct(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || isFileType(reflect.PtrTo(typ)) {
+		return false
+	}
+	if _, ok := getConverter(typ); ok {
+		return false
+	}
+	return !reflect.PtrTo(typ).Implements(textUnmarshalerType)
+}
+
+// setField converts vals to the type of field and sets it, empty vals
+// leave the field unchanged
+func setField(field reflect.Value, vals []string) error {
+	if len(vals) == 0 {
+		return nil
+	}
+	typ := field.Type()
+	if converter, ok := getConverter(typ); ok {
+		if vals[0] == "" {
+			return nil
+		}
+		val, err := converter(vals[0])
+		if err != nil {
+			return err
+		}
+		rv := reflect.ValueOf(val)
+		if !rv.IsValid() || !rv.Type().AssignableTo(typ) {
+			return fmt.Errorf("converter returns %T for type %s", val, typ)
+		}
+		field.Set(rv)
+		return nil
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		if vals[0] == "" && len(vals) == 1 {
+			return nil
+		}
+		elem := reflect.New(typ.Elem())
+		if err := setField(elem.Elem(), vals); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if field.CanAddr() && reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(vals[0]))
+	}
+
+	switch typ.Kind() {
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			field.SetBytes([]byte(vals[0]))
+			return nil
+		}
+		slice := reflect.MakeSlice(typ, len(vals), len(vals))
+		for idx, val := range vals {
+			if err := setField(slice.Index(idx), []string{val}); err != nil {
+				return fmt.Errorf("item %d: %w", idx, err)
+			}
+		}
+		field.Set(slice)
+		return nil
+	case reflect.Array:
+		if len(vals) > typ.Len() {
+			return fmt.Errorf("too many values for %s", typ)
+		}
+		for idx, val := range vals {
+			if err := setField(field.Index(idx), []string{val}); err != nil {
+				return fmt.Errorf("item %d: %w", idx, err)
+			}
+		}
+		return nil
+	}
+	return setScalar(field, vals[0])
+}
+
+func setScalar(field reflect.Value, val string) error {
+	if val == "" {
+		return nil
+	}
+	typ := field.Type()
+	switch typ.Kind() {
+	case reflect.String:
+		field.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		num, err := strconv.ParseInt(val, 10, typ.Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(num)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		num, err := strconv.ParseUint(val, 10, typ.Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(num)
+	case reflect.Float32, reflect.Float64:
+		num, err := strconv.ParseFloat(val, typ.Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(num)
+	case reflect.Interface:
+		if typ.NumMethod() != 0 {
+			return fmt.Errorf("unsupported type %s", typ)
+		}
+		field.Set(reflect.ValueOf(val))
+	default:
+		return fmt.Errorf("unsupported type %s", typ)
+	}
+	return nil
+}
+
+// bindForm fills fields with form values and uploaded files, field name
+// or form tag is used as key. Like bindTags, recursive types terminate
+func bindForm(form url.Values, files map[string][]*multipart.FileHeader, limits FileLimits, objVal reflect.Value, visiting map[reflect.Type]bool) BindErrors {
+	errs := BindErrors{}
+	objType := objVal.Type()
+	if visiting == nil {
+		visiting = map[reflect.Type]bool{}
+	}
+	if visiting[objType] {
+		return errs
+	}
+	visiting[objType] = true
+	defer delete(visiting, objType)
+
+	for idx := 0; idx < objType.NumField(); idx++ {
+		field := objVal.Field(idx)
+		if !field.CanSet() {
+			continue
+		}
+		fieldType := objType.Field(idx)
+		fieldName, ok := getformName(fieldType)
+		if !ok {
+			continue
+		}
+		if isFileType(fieldType.Type) {
+			if err := setFiles(field, files[fieldName], limits); err != nil {
+				errs = append(errs, newBindError(fieldName, "file", "", err))
+			}
+			continue
+		}
+		vals, found := form[fieldName]
+		if !found && isNestedStruct(fieldType.Type) {
+			errs = append(errs, bindNested(field, func(nested reflect.Value) BindErrors {
+				return bindForm(form, files, limits, nested, visiting)
+			})...)
+			continue
+		}
+		if err := setField(field, vals); err != nil {
+			errs = append(errs, newBindError(fieldName, "form", strings.Join(vals, ","), err))
+		}
+	}
+	return errs
+}
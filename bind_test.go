@@ -0,0 +1,92 @@
+package cupcake
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bindNode struct {
+	Name  string    `json:"name" form:"name"`
+	Depth int       `query:"depth"`
+	Next  *bindNode `json:"next"`
+	Tree  struct {
+		Parent *bindNode `json:"parent"`
+	} `json:"tree"`
+}
+
+func TestBindRecursiveType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{ApplicationJSON, `{"name":"a","next":{"name":"b"}}`},
+		{ApplicationForm, "name=a"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/nodes?depth=2", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", test.contentType)
+		var node bindNode
+		if err := NewRequest(r).Bind(&node); err != nil {
+			t.Fatalf("%s: %v", test.contentType, err)
+		}
+		if node.Name != "a" || node.Depth != 2 {
+			t.Errorf("%s: got %+v", test.contentType, node)
+		}
+		if test.contentType == ApplicationJSON && (node.Next == nil || node.Next.Name != "b") {
+			t.Errorf("%s: got next %+v", test.contentType, node.Next)
+		}
+		if node.Tree.Parent != nil {
+			t.Errorf("%s: got parent %+v, want nil", test.contentType, node.Tree.Parent)
+		}
+	}
+}
+
+func TestBindErrorMessage(t *testing.T) {
+	type query struct {
+		Page int `query:"page"`
+	}
+	cc := New()
+	cc.GET("/items", func(resp *Response, req *Request) {
+		var q query
+		if err := req.Bind(&q); err != nil {
+			resp.BindError(err)
+		}
+	})
+	w := httptest.NewRecorder()
+	cc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items?page=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"field":"page"`) || !strings.Contains(body, `"message":"strconv.ParseInt: parsing \"x\": invalid syntax"`) {
+		t.Errorf("got %s", body)
+	}
+}
+
+func TestBindKeepsUnknownBody(t *testing.T) {
+	type upload struct {
+		Name string `uri:"name"`
+		Size int    `header:"X-Size"`
+	}
+	cc := New()
+	cc.PUT("/blobs/{name}", func(resp *Response, req *Request) {
+		var u upload
+		if err := req.Bind(&u); err != nil {
+			resp.BindError(err)
+			return
+		}
+		data := make([]byte, u.Size)
+		n, _ := req.Body().Read(data)
+		resp.String(http.StatusOK, "%s %s", u.Name, data[:n])
+	})
+	r := httptest.NewRequest(http.MethodPut, "/blobs/a.bin", strings.NewReader("raw"))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	r.Header.Set("X-Size", "3")
+	w := httptest.NewRecorder()
+	cc.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "a.bin raw" {
+		t.Errorf("got %d %q", w.Code, w.Body.String())
+	}
+}
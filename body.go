@@ -0,0 +1,167 @@
+package cupcake
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Max size of decompressed request body by default
+const defaultMaxDecompressedSize = 32 << 20
+
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// MaxBodySize sets max size of request body in bytes for routes under this
+// group, 0 means inheriting from parent group and negative means no limit.
+// Requests exceeding the limit are responded with 413
+func (group *RouteGroup) MaxBodySize(size int64) {
+	group.maxBodySize = size
+}
+
+// bodyLimit returns max body size applied to the group, 0 means no limit
+func (group *RouteGroup) bodyLimit() int64 {
+	for g := group; g != nil; g = g.parent {
+		if g.maxBodySize < 0 {
+			return 0
+		}
+		if g.maxBodySize > 0 {
+			return g.maxBodySize
+		}
+	}
+	return 0
+}
+
+// limitedReader returns ErrBodyTooLarge once more than limit bytes are read,
+// 0 means no limit
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.limit > 0 {
+		if l.read > l.limit {
+			return 0, ErrBodyTooLarge
+		}
+		// Read one more byte to know whether the limit is exceeded
+		if remain := l.limit - l.read + 1; int64(len(p)) > remain {
+			p = p[:remain]
+		}
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.limit > 0 && l.read > l.limit {
+		return n, ErrBodyTooLarge
+	}
+	return n, err
+}
+
+// bodyReader reads request body lazily with size limits, gzip body is
+// decompressed when it is read
+type bodyReader struct {
+	raw     io.ReadCloser
+	limited *limitedReader
+	// Decompressed body, nil before the first read
+	reader          io.Reader
+	gzip            bool
+	maxDecompressed int64
+	err             error
+}
+
+func newBodyReader(req *http.Request, maxDecompressed int64) *bodyReader {
+	body := &bodyReader{
+		raw:             req.Body,
+		limited:         &limitedReader{r: req.Body},
+		maxDecompressed: maxDecompressed,
+	}
+	for _, val := range strings.Split(req.Header.Get("Content-Encoding"), ",") {
+		if strings.TrimSpace(val) == "gzip" {
+			body.gzip = true
+		}
+	}
+	return body
+}
+
+func (body *bodyReader) Read(p []byte) (int, error) {
+	if body.err != nil {
+		return 0, body.err
+	}
+	if body.reader == nil {
+		body.reader = body.limited
+		if body.gzip {
+			gReader, err := gzip.NewReader(body.limited)
+			if err != nil {
+				body.err = err
+				return 0, err
+			}
+			body.reader = &limitedReader{r: gReader, limit: body.maxDecompressed}
+		}
+	}
+	n, err := body.reader.Read(p)
+	if errors.Is(err, ErrBodyTooLarge) {
+		body.err = ErrBodyTooLarge
+	}
+	return n, err
+}
+
+func (body *bodyReader) Close() error {
+	return body.raw.Close()
+}
+
+// limitBody applies size limits to body of the request, the request is
+// responded with 413 if its Content-Length exceeds limit already. Limits
+// set by mounting engines are kept if they are smaller
+func (r *Request) limitBody(resp *Response, limit int64, maxDecompressed int64) bool {
+	if r.req.Body == nil || r.req.Body == http.NoBody {
+		return true
+	}
+	if r.body == nil {
+		r.body = newBodyReader(r.req, maxDecompressed)
+		r.req.Body = r.body
+	}
+	if limit > 0 && (r.body.limited.limit == 0 || limit < r.body.limited.limit) {
+		r.body.limited.limit = limit
+	}
+	if limit := r.body.limited.limit; limit > 0 && r.req.ContentLength > limit {
+		resp.Error(http.StatusRequestEntityTooLarge, fmt.Sprintf("%s: max %d bytes", ErrBodyTooLarge, limit))
+		return false
+	}
+	return true
+}
+
+// peekedBody is body of request whose first bytes have been peeked
+type peekedBody struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// peekBody reports whether the body is not empty without consuming it
+func (r *Request) peekBody() (bool, error) {
+	if r.req.Body == nil || r.req.Body == http.NoBody {
+		return false, nil
+	}
+	buffered := bufio.NewReader(r.req.Body)
+	_, err := buffered.Peek(1)
+	r.req.Body = peekedBody{buffered, r.req.Body}
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, r.bodyError(err)
+	}
+	return true, nil
+}
+
+// bodyError returns ErrBodyTooLarge if err is caused by exceeding body
+// limits, as parsers of forms do not wrap errors of reading body
+func (r *Request) bodyError(err error) error {
+	if r.body != nil && errors.Is(r.body.err, ErrBodyTooLarge) {
+		return ErrBodyTooLarge
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package cupcake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipBody(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Write(data)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMaxBodySize(t *testing.T) {
+	cc := New(WithMaxBodySize(16))
+	read := func(resp *Response, req *Request) {
+		data, err := ioutil.ReadAll(req.Body())
+		if errors.Is(err, ErrBodyTooLarge) {
+			resp.Error(http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		resp.String(http.StatusOK, "%d", len(data))
+	}
+	cc.POST("/small", read)
+	uploads := cc.Group("/uploads")
+	uploads.MaxBodySize(64)
+	uploads.POST("/", read)
+	unlimited := uploads.Group("/raw")
+	unlimited.MaxBodySize(-1)
+	unlimited.POST("/", read)
+
+	tests := []struct {
+		target string
+		size   int
+		// Whether Content-Length is sent
+		known bool
+		code  int
+	}{
+		{"/small", 16, true, http.StatusOK},
+		{"/small", 17, true, http.StatusRequestEntityTooLarge},
+		{"/small", 17, false, http.StatusRequestEntityTooLarge},
+		{"/uploads/", 64, false, http.StatusOK},
+		{"/uploads/", 65, true, http.StatusRequestEntityTooLarge},
+		{"/uploads/raw/", 1024, true, http.StatusOK},
+	}
+	for _, test := range tests {
+		body := strings.Repeat("x", test.size)
+		var r *http.Request
+		if test.known {
+			r = httptest.NewRequest(http.MethodPost, test.target, strings.NewReader(body))
+		} else {
+			r = httptest.NewRequest(http.MethodPost, test.target, io.MultiReader(strings.NewReader(body)))
+		}
+		w := httptest.NewRecorder()
+		cc.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s %d bytes, known %v: got %d, want %d", test.target, test.size, test.known, w.Code, test.code)
+		}
+	}
+}
+
+func TestGzipBody(t *testing.T) {
+	cc := New(WithMaxBodySize(1024), WithMaxDecompressedSize(4096))
+	var parseErr error
+	cc.POST("/echo", func(resp *Response, req *Request) {
+		data, err := ioutil.ReadAll(req.Body())
+		parseErr = err
+		resp.String(http.StatusOK, "%s", data)
+	})
+
+	tests := []struct {
+		data []byte
+		err  error
+	}{
+		{[]byte("hello cupcake"), nil},
+		{bytes.Repeat([]byte("x"), 4096), nil},
+		// Small compressed body expanding beyond the limit
+		{bytes.Repeat([]byte("x"), 4097), ErrBodyTooLarge},
+		{bytes.Repeat([]byte("x"), 256<<10), ErrBodyTooLarge},
+	}
+	for _, test := range tests {
+		compressed := gzipBody(t, test.data)
+		if len(compressed) > 1024 {
+			t.Fatalf("compressed body of %d bytes exceeds max body size", len(compressed))
+		}
+		r := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(compressed))
+		r.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		cc.ServeHTTP(w, r)
+		if !errors.Is(parseErr, test.err) {
+			t.Errorf("%d bytes: got %v, want %v", len(test.data), parseErr, test.err)
+		}
+		if test.err == nil && w.Body.String() != string(test.data) {
+			t.Errorf("%d bytes: got %d bytes", len(test.data), w.Body.Len())
+		}
+	}
+}
+
+func TestBindBodyTooLarge(t *testing.T) {
+	cc := New(WithMaxBodySize(16))
+	cc.POST("/users", func(resp *Response, req *Request) {
+		var user struct {
+			Name string `json:"name"`
+		}
+		if err := req.Bind(&user); err != nil {
+			resp.BindError(err)
+			return
+		}
+		resp.String(http.StatusOK, user.Name)
+	})
+
+	body := `{"name":"` + strings.Repeat("x", 32) + `"}`
+	r := httptest.NewRequest(http.MethodPost, "/users", io.MultiReader(strings.NewReader(body)))
+	r.Header.Set("Content-Type", ApplicationJSON)
+	w := httptest.NewRecorder()
+	cc.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
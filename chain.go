@@ -0,0 +1,30 @@
+package cupcake
+
+// Chain is a list of middlewares, the first middleware is the outermost
+// and the last one is the innermost
+type Chain []MiddlerWare
+
+func NewChain(middlewares ...MiddlerWare) Chain {
+	return append(Chain{}, middlewares...)
+}
+
+// Append returns a new chain with middlewares appended to the inner end
+func (c Chain) Append(middlewares ...MiddlerWare) Chain {
+	chain := make(Chain, 0, len(c)+len(middlewares))
+	chain = append(chain, c...)
+	return append(chain, middlewares...)
+}
+
+// Extend returns a new chain with middlewares of other chain appended
+func (c Chain) Extend(other Chain) Chain {
+	return c.Append(other...)
+}
+
+// Then wraps handler with the chain, it can also be used as a MiddlerWare:
+// group.MiddlerWare(chain.Then)
+func (c Chain) Then(handler HandlerFunc) HandlerFunc {
+	for idx := len(c) - 1; idx >= 0; idx-- {
+		handler = c[idx](handler)
+	}
+	return handler
+}
@@ -0,0 +1,333 @@
+package cupcake
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Codec decodes request body and encodes response body of a media type
+type Codec interface {
+	Decode(r io.Reader, obj interface{}) error
+	Encode(w io.Writer, obj interface{}) error
+}
+
+var (
+	codecMu sync.RWMutex
+	codecs  = map[string]Codec{
+		ApplicationJSON:    jsonCodec{},
+		ApplicationXML:     xmlCodec{},
+		TextXML:            xmlCodec{},
+		ApplicationYAML:    yamlCodec{},
+		"application/yaml": yamlCodec{},
+		"text/yaml":        yamlCodec{},
+		ApplicationProto:   protoCodec{},
+		ApplicationNDJSON:  ndjsonCodec{},
+		TextCSV:            csvCodec{},
+	}
+)
+
+// RegisterCodec registers codec for the media type, which is used by
+// Request.Parse and Response.Encode. Codecs of built-in media types can
+// be replaced:
+//
+//	cupcake.RegisterCodec("application/msgpack", msgpackCodec{})
+func RegisterCodec(mediaType string, codec Codec) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	codecs[normalizeMediaType(mediaType)] = codec
+}
+
+func getCodec(mediaType string) (Codec, bool) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	codec, ok := codecs[normalizeMediaType(mediaType)]
+	return codec, ok
+}
+
+// normalizeMediaType removes parameters from media type and lowercases it
+func normalizeMediaType(mediaType string) string {
+	if idx := strings.IndexByte(mediaType, ';'); idx >= 0 {
+		mediaType = mediaType[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(r io.Reader, obj interface{}) error {
+	return json.NewDecoder(r).Decode(obj)
+}
+
+func (jsonCodec) Encode(w io.Writer, obj interface{}) error {
+	return json.NewEncoder(w).Encode(obj)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Decode(r io.Reader, obj interface{}) error {
+	return xml.NewDecoder(r).Decode(obj)
+}
+
+func (xmlCodec) Encode(w io.Writer, obj interface{}) error {
+	return xml.NewEncoder(w).Encode(obj)
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Decode(r io.Reader, obj interface{}) error {
+	return yaml.NewDecoder(r).Decode(obj)
+}
+
+func (yamlCodec) Encode(w io.Writer, obj interface{}) error {
+	encoder := yaml.NewEncoder(w)
+	if err := encoder.Encode(obj); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Decode(r io.Reader, obj interface{}) error {
+	msg, ok := obj.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a proto message", obj)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+func (protoCodec) Encode(w io.Writer, obj interface{}) error {
+	msg, ok := obj.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a proto message", obj)
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ndjsonCodec decodes newline delimited JSON into pointer to slice, and
+// encodes each item of slice as a line
+type ndjsonCodec struct{}
+
+func (ndjsonCodec) Decode(r io.Reader, obj interface{}) error {
+	sliceVal, err := slicePointer(obj)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		item := reflect.New(sliceVal.Type().Elem())
+		if err := decoder.Decode(item.Interface()); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("item %d: %w", line, err)
+		}
+		sliceVal.Set(reflect.Append(sliceVal, item.Elem()))
+	}
+}
+
+func (ndjsonCodec) Encode(w io.Writer, obj interface{}) error {
+	encoder := json.NewEncoder(w)
+	val := reflect.Indirect(reflect.ValueOf(obj))
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return encoder.Encode(obj)
+	}
+	for idx := 0; idx < val.Len(); idx++ {
+		if err := encoder.Encode(val.Index(idx).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvCodec decodes CSV into pointer to [][]string or slice of structs, the
+// first row is the header matched with csv tags or names of fields
+type csvCodec struct{}
+
+func (csvCodec) Decode(r io.Reader, obj interface{}) error {
+	if rows, ok := obj.(*[][]string); ok {
+		records, err := csv.NewReader(r).ReadAll()
+		*rows = records
+		return err
+	}
+	sliceVal, err := slicePointer(obj)
+	if err != nil {
+		return err
+	}
+	itemType := sliceVal.Type().Elem()
+	structType := itemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("csv can not be decoded into %s", sliceVal.Type())
+	}
+
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	fields := csvFields(structType)
+	columns := make([]int, len(header))
+	for col, name := range header {
+		columns[col] = -1
+		for idx, field := range fields {
+			if strings.EqualFold(field.name, strings.TrimSpace(name)) {
+				columns[col] = idx
+			}
+		}
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		item := reflect.New(structType)
+		for col, val := range record {
+			if col >= len(columns) || columns[col] < 0 {
+				continue
+			}
+			field := fields[columns[col]]
+			if err := setField(item.Elem().Field(field.index), []string{val}); err != nil {
+				return fmt.Errorf("line %d, column %s: %w", line, field.name, err)
+			}
+		}
+		if itemType.Kind() != reflect.Ptr {
+			item = item.Elem()
+		}
+		sliceVal.Set(reflect.Append(sliceVal, item))
+	}
+}
+
+func (csvCodec) Encode(w io.Writer, obj interface{}) error {
+	writer := csv.NewWriter(w)
+	if rows, ok := obj.([][]string); ok {
+		return writer.WriteAll(rows)
+	}
+	val := reflect.Indirect(reflect.ValueOf(obj))
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return fmt.Errorf("%T can not be encoded into csv", obj)
+	}
+	structType := val.Type().Elem()
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("%T can not be encoded into csv", obj)
+	}
+
+	fields := csvFields(structType)
+	record := make([]string, len(fields))
+	for idx, field := range fields {
+		record[idx] = field.name
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	for row := 0; row < val.Len(); row++ {
+		item := reflect.Indirect(val.Index(row))
+		for idx, field := range fields {
+			record[idx] = ""
+			if item.IsValid() {
+				record[idx] = csvValue(item.Field(field.index))
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type csvField struct {
+	name  string
+	index int
+}
+
+// csvFields returns exported fields of struct with their names in csv tags
+func csvFields(typ reflect.Type) []csvField {
+	fields := []csvField{}
+	for idx := 0; idx < typ.NumField(); idx++ {
+		field := typ.Field(idx)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("csv"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, csvField{name: name, index: idx})
+	}
+	return fields
+}
+
+func csvValue(field reflect.Value) string {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return ""
+		}
+		field = field.Elem()
+	}
+	if marshaler, ok := field.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// slicePointer returns the slice obj points to
+func slicePointer(obj interface{}) (reflect.Value, error) {
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, errors.New("given obj is not pointer to a slice")
+	}
+	return val.Elem(), nil
+}
+
+// Encode responds obj encoded by codec of the media type, nothing is written
+// if there is no such codec or obj fails to be encoded
+func (resp *Response) Encode(code int, mediaType string, obj interface{}) error {
+	codec, ok := getCodec(mediaType)
+	if !ok {
+		return fmt.Errorf("no codec for media type %s", mediaType)
+	}
+	var buffer bytes.Buffer
+	if err := codec.Encode(&buffer, obj); err != nil {
+		return err
+	}
+	resp.SetHeader("Content-Type", mediaType).Status(code).write(buffer.Bytes())
+	return nil
+}
@@ -0,0 +1,110 @@
+package cupcake
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type codecItem struct {
+	ID int `json:"id"`
+}
+
+func TestDecoderStreamsBody(t *testing.T) {
+	received := make(chan int)
+	cc := New()
+	cc.POST("/events", func(resp *Response, req *Request) {
+		decoder := req.Decoder()
+		for {
+			var item codecItem
+			if err := decoder.Decode(&item); err == io.EOF {
+				break
+			} else if err != nil {
+				resp.Error(http.StatusBadRequest, err.Error())
+				return
+			}
+			received <- item.ID
+		}
+		resp.Status(http.StatusNoContent)
+	})
+
+	pr, pw := io.Pipe()
+	r := httptest.NewRequest(http.MethodPost, "/events", pr)
+	r.Header.Set("Content-Type", ApplicationNDJSON)
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		cc.ServeHTTP(w, r)
+		close(done)
+	}()
+
+	// Each item is handled before the next one is sent
+	for id := 1; id <= 3; id++ {
+		if _, err := io.WriteString(pw, `{"id":`+string(rune('0'+id))+"}\n"); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-received:
+			if got != id {
+				t.Fatalf("got item %d, want %d", got, id)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("item %d was not decoded before the body ends", id)
+		}
+	}
+	pw.Close()
+	<-done
+	if w.Code != http.StatusNoContent {
+		t.Errorf("got %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestParseNDJSON(t *testing.T) {
+	tests := []struct {
+		body  string
+		limit int64
+		ids   []int
+		err   error
+	}{
+		{"{\"id\":1}\n{\"id\":2}\n", 0, []int{1, 2}, nil},
+		{"", 0, nil, nil},
+		{strings.Repeat("{\"id\":1}\n", 10), 32, nil, ErrBodyTooLarge},
+	}
+	for _, test := range tests {
+		var items []codecItem
+		var parseErr error
+		cc := New()
+		cc.MaxBodySize(test.limit)
+		cc.POST("/events", func(resp *Response, req *Request) {
+			parseErr = req.Parse(&items)
+		})
+		// Body of unknown length is only limited when it is read
+		r := httptest.NewRequest(http.MethodPost, "/events", io.MultiReader(strings.NewReader(test.body)))
+		r.Header.Set("Content-Type", ApplicationNDJSON)
+		cc.ServeHTTP(httptest.NewRecorder(), r)
+
+		if test.err != nil {
+			if !errors.Is(parseErr, test.err) {
+				t.Errorf("%q: got %v, want %v", test.body, parseErr, test.err)
+			}
+			continue
+		}
+		if parseErr != nil {
+			t.Errorf("%q: got %v", test.body, parseErr)
+			continue
+		}
+		if len(items) != len(test.ids) {
+			t.Errorf("%q: got %v, want ids %v", test.body, items, test.ids)
+			continue
+		}
+		for idx, item := range items {
+			if item.ID != test.ids[idx] {
+				t.Errorf("%q: got %v, want ids %v", test.body, items, test.ids)
+			}
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lz-nsc/cupcake/log"
+	"github.com/lz-nsc/cupcake/orm/translator"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings of a cupcake project, which can be loaded from YAML or JSON file
+// and overridden by environment variables:
+//
+//	addr: ":8080"
+//	log_level: info
+//	database:
+//	  driver: sqlite3
+//	  dsn: cupcake.db
+//	templates: templates/*
+//	statics:
+//	  - pattern: /static
+//	    dir: ./public
+//	middlewares: [recovery, logger]
+//	profiles:
+//	  prod:
+//	    addr: ":80"
+//	    log_level: error
+type Settings struct {
+	Addr        string   `json:"addr" yaml:"addr"`
+	LogLevel    string   `json:"log_level" yaml:"log_level"`
+	Database    Database `json:"database" yaml:"database"`
+	Templates   string   `json:"templates" yaml:"templates"`
+	Statics     []Static `json:"statics" yaml:"statics"`
+	Middlewares []string `json:"middlewares" yaml:"middlewares"`
+	// Profile the settings are loaded with
+	Profile string `json:"-" yaml:"-"`
+}
+
+type Database struct {
+	Driver string `json:"driver" yaml:"driver"`
+	DSN    string `json:"dsn" yaml:"dsn"`
+}
+
+// Static serves files in Dir under path Pattern
+type Static struct {
+	Pattern string `json:"pattern" yaml:"pattern"`
+	Dir     string `json:"dir" yaml:"dir"`
+}
+
+// Environment variables which override settings from file
+const (
+	EnvProfile     = "CUPCAKE_PROFILE"
+	EnvAddr        = "CUPCAKE_ADDR"
+	EnvLogLevel    = "CUPCAKE_LOG_LEVEL"
+	EnvDBDriver    = "CUPCAKE_DB_DRIVER"
+	EnvDBDSN       = "CUPCAKE_DB_DSN"
+	EnvTemplates   = "CUPCAKE_TEMPLATES"
+	EnvStatics     = "CUPCAKE_STATICS"     // e.g. /static=./public,/assets=./assets
+	EnvMiddlewares = "CUPCAKE_MIDDLEWARES" // e.g. recovery,logger
+)
+
+// Default returns the default settings
+func Default() *Settings {
+	return &Settings{
+		Addr:     ":8080",
+		LogLevel: "info",
+		Database: Database{
+			Driver: "sqlite3",
+			DSN:    "cupcake.db",
+		},
+	}
+}
+
+// Load loads settings from file with given profile, then applies environment
+// variables. The file is skipped if path is empty, and profile is read from
+// CUPCAKE_PROFILE if it is empty. The loaded settings are validated.
+func Load(path string, profile string) (*Settings, error) {
+	settings := Default()
+	if profile == "" {
+		profile = os.Getenv(EnvProfile)
+	}
+	settings.Profile = profile
+
+	if path != "" {
+		if err := settings.loadFile(path, profile); err != nil {
+			return nil, err
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile '%s' is given without settings file", profile)
+	}
+	if err := settings.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (s *Settings) loadFile(path string, profile string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read settings file, err: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var file struct {
+			Profiles map[string]yaml.Node `yaml:"profiles"`
+		}
+		if err := yaml.Unmarshal(data, s); err != nil {
+			return fmt.Errorf("failed to parse settings file %s, err: %w", path, err)
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("failed to parse settings file %s, err: %w", path, err)
+		}
+		if profile == "" {
+			return nil
+		}
+		node, ok := file.Profiles[profile]
+		if !ok {
+			return fmt.Errorf("profile '%s' not found in %s", profile, path)
+		}
+		// Fields set in profile override the base ones
+		if err := node.Decode(s); err != nil {
+			return fmt.Errorf("failed to parse profile '%s', err: %w", profile, err)
+		}
+	case ".json":
+		var file struct {
+			Profiles map[string]json.RawMessage `json:"profiles"`
+		}
+		if err := json.Unmarshal(data, s); err != nil {
+			return fmt.Errorf("failed to parse settings file %s, err: %w", path, err)
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("failed to parse settings file %s, err: %w", path, err)
+		}
+		if profile == "" {
+			return nil
+		}
+		raw, ok := file.Profiles[profile]
+		if !ok {
+			return fmt.Errorf("profile '%s' not found in %s", profile, path)
+		}
+		if err := json.Unmarshal(raw, s); err != nil {
+			return fmt.Errorf("failed to parse profile '%s', err: %w", profile, err)
+		}
+	default:
+		return fmt.Errorf("unsupported settings file %s, should be YAML or JSON", path)
+	}
+	return nil
+}
+
+func (s *Settings) loadEnv() error {
+	if val, ok := os.LookupEnv(EnvAddr); ok {
+		s.Addr = val
+	}
+	if val, ok := os.LookupEnv(EnvLogLevel); ok {
+		s.LogLevel = val
+	}
+	if val, ok := os.LookupEnv(EnvDBDriver); ok {
+		s.Database.Driver = val
+	}
+	if val, ok := os.LookupEnv(EnvDBDSN); ok {
+		s.Database.DSN = val
+	}
+	if val, ok := os.LookupEnv(EnvTemplates); ok {
+		s.Templates = val
+	}
+	if val, ok := os.LookupEnv(EnvStatics); ok {
+		s.Statics = nil
+		for _, item := range splitList(val) {
+			idx := strings.Index(item, "=")
+			if idx < 0 {
+				return fmt.Errorf("invalid %s item '%s', should be pattern=dir", EnvStatics, item)
+			}
+			s.Statics = append(s.Statics, Static{Pattern: item[:idx], Dir: item[idx+1:]})
+		}
+	}
+	if val, ok := os.LookupEnv(EnvMiddlewares); ok {
+		s.Middlewares = splitList(val)
+	}
+	return nil
+}
+
+// Validate checks the settings and reports all problems found
+func (s *Settings) Validate() error {
+	problems := []string{}
+	if _, err := log.ParseLevel(s.LogLevel); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if s.Database.Driver == "" {
+		problems = append(problems, "database driver is required")
+	} else if _, ok := translator.GetTranslator(s.Database.Driver); !ok {
+		problems = append(problems, fmt.Sprintf("database driver '%s' is not supported", s.Database.Driver))
+	}
+	if s.Database.DSN == "" {
+		problems = append(problems, "database dsn is required")
+	}
+	if s.Templates != "" {
+		matches, err := filepath.Glob(s.Templates)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid templates pattern '%s'", s.Templates))
+		} else if len(matches) == 0 {
+			problems = append(problems, fmt.Sprintf("no template matches '%s'", s.Templates))
+		}
+	}
+	for _, static := range s.Statics {
+		if static.Pattern == "" {
+			problems = append(problems, fmt.Sprintf("pattern of static dir '%s' is required", static.Dir))
+		}
+		if info, err := os.Stat(static.Dir); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("static dir '%s' does not exist", static.Dir))
+		}
+	}
+	for _, name := range s.Middlewares {
+		if name == "" {
+			problems = append(problems, "middleware name cannot be empty")
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid settings: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func splitList(val string) []string {
+	items := []string{}
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
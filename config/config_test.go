@@ -0,0 +1,157 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const yamlSettings = `
+addr: ":9000"
+log_level: debug
+database:
+  driver: sqlite3
+  dsn: dev.db
+middlewares: [recovery, logger]
+profiles:
+  prod:
+    addr: ":80"
+    log_level: error
+    database:
+      dsn: prod.db
+`
+
+const jsonSettings = `{
+	"addr": ":9000",
+	"log_level": "debug",
+	"database": {"driver": "sqlite3", "dsn": "dev.db"},
+	"middlewares": ["recovery", "logger"],
+	"profiles": {
+		"prod": {"addr": ":80", "log_level": "error", "database": {"dsn": "prod.db"}}
+	}
+}`
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProfiles(t *testing.T) {
+	files := map[string]string{
+		"settings.yaml": yamlSettings,
+		"settings.json": jsonSettings,
+	}
+	for name, content := range files {
+		path := writeFile(t, name, content)
+
+		settings, err := Load(path, "")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if settings.Addr != ":9000" || settings.LogLevel != "debug" || settings.Database.DSN != "dev.db" {
+			t.Errorf("%s: got %+v", name, settings)
+		}
+
+		settings, err = Load(path, "prod")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// Fields not set in profile are kept from the base settings
+		want := &Settings{
+			Addr:        ":80",
+			LogLevel:    "error",
+			Database:    Database{Driver: "sqlite3", DSN: "prod.db"},
+			Middlewares: []string{"recovery", "logger"},
+			Profile:     "prod",
+		}
+		if !reflect.DeepEqual(settings, want) {
+			t.Errorf("%s: got %+v, want %+v", name, settings, want)
+		}
+
+		if _, err := Load(path, "staging"); err == nil || !strings.Contains(err.Error(), "profile 'staging' not found") {
+			t.Errorf("%s: got %v, want profile not found", name, err)
+		}
+	}
+}
+
+func TestLoadProfileFromEnv(t *testing.T) {
+	path := writeFile(t, "settings.yaml", yamlSettings)
+	t.Setenv(EnvProfile, "prod")
+	settings, err := Load(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Profile != "prod" || settings.Addr != ":80" {
+		t.Errorf("got %+v", settings)
+	}
+
+	if _, err := Load("", ""); err == nil {
+		t.Error("got nil, want error for profile without settings file")
+	}
+}
+
+func TestLoadEnvOverrides(t *testing.T) {
+	path := writeFile(t, "settings.yaml", yamlSettings)
+	static := t.TempDir()
+	t.Setenv(EnvAddr, ":7000")
+	t.Setenv(EnvLogLevel, "info")
+	t.Setenv(EnvDBDSN, "env.db")
+	t.Setenv(EnvStatics, "/static="+static)
+	t.Setenv(EnvMiddlewares, " cors , ")
+
+	settings, err := Load(path, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Settings{
+		Addr:        ":7000",
+		LogLevel:    "info",
+		Database:    Database{Driver: "sqlite3", DSN: "env.db"},
+		Statics:     []Static{{Pattern: "/static", Dir: static}},
+		Middlewares: []string{"cors"},
+		Profile:     "prod",
+	}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("got %+v, want %+v", settings, want)
+	}
+
+	t.Setenv(EnvStatics, "/static")
+	if _, err := Load(path, ""); err == nil || !strings.Contains(err.Error(), "should be pattern=dir") {
+		t.Errorf("got %v, want invalid statics error", err)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := writeFile(t, "settings.yaml", `
+log_level: loud
+database:
+  driver: oracle
+  dsn: ""
+statics:
+  - dir: ./missing
+`)
+	_, err := Load(path, "")
+	if err == nil {
+		t.Fatal("got nil, want error")
+	}
+	// All problems are reported together
+	for _, problem := range []string{"loud", "'oracle' is not supported", "dsn is required", "pattern of static dir", "'./missing' does not exist"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("error %q does not report %q", err, problem)
+		}
+	}
+
+	if _, err := Load(writeFile(t, "settings.toml", ""), ""); err == nil {
+		t.Error("got nil, want error for unsupported file")
+	}
+	if _, err := Load(filepath.Join(os.TempDir(), "missing.yaml"), ""); err == nil {
+		t.Error("got nil, want error for missing file")
+	}
+}
@@ -0,0 +1,54 @@
+package cupcake
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+var (
+	constraintMu sync.RWMutex
+	// Named constraints which can be used in path like {id:int}
+	constraints = map[string]string{
+		"int":   `-?[0-9]+`,
+		"uint":  `[0-9]+`,
+		"float": `-?[0-9]+(\.[0-9]+)?`,
+		"bool":  `true|false|1|0`,
+		"alpha": `[a-zA-Z]+`,
+		"alnum": `[a-zA-Z0-9]+`,
+		"slug":  `[a-z0-9]+(-[a-z0-9]+)*`,
+		"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+		"date":  `[0-9]{4}-[0-9]{2}-[0-9]{2}`,
+	}
+)
+
+// RegisterConstraint registers a named constraint for path params, the pattern
+// must match the whole segment, e.g.
+// cupcake.RegisterConstraint("hex", "[0-9a-f]+")
+// cc.GET("/colors/{color:hex}", handler)
+func RegisterConstraint(name string, pattern string) {
+	if _, err := regexp.Compile(pattern); err != nil {
+		panic(fmt.Sprintf("Invalid regexp pattern '%s' for constraint '%s'", pattern, name))
+	}
+	constraintMu.Lock()
+	defer constraintMu.Unlock()
+	constraints[name] = pattern
+}
+
+// resolveConstraint returns anchored regexp of the named constraint, or of the
+// pattern itself if there is no constraint with this name, so the regexp
+// always has to match the whole segment
+func resolveConstraint(pattern string) string {
+	return "^(?:" + constraintRegex(pattern) + ")$"
+}
+
+// constraintRegex returns unanchored regexp of the named constraint, or the
+// pattern itself if there is no constraint with this name
+func constraintRegex(pattern string) string {
+	constraintMu.RLock()
+	defer constraintMu.RUnlock()
+	if rex, ok := constraints[pattern]; ok {
+		return "(?:" + rex + ")"
+	}
+	return pattern
+}
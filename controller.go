@@ -51,29 +51,29 @@ func (base *BaseController) Create(resp *Response, req *Request) {
 		return
 	}
 
+	// Abort queries when client disconnects
+	session := base.session.WithContext(req.Context())
 	// Check whether table exist in database
-	if exists := base.session.HasTable(); !exists {
+	if exists := session.HasTable(); !exists {
 		// If not, create table
-		err := base.session.CreateTable()
+		err := session.CreateTable()
 		if err != nil {
-			log.Errorf("failed to create table for model %s", base.session.ModelName())
+			log.Errorf("failed to create table for model %s", session.ModelName())
 			resp.Error(http.StatusInternalServerError, "Internal Error")
 		}
 	}
 	instance := reflect.New(reflect.Indirect(reflect.ValueOf(base.Model)).Type()).Interface()
 
-	// Parse request data
-	// TODO: Currently can only accept JSON, whether I should consider how to parse Postform
-	err := req.Parse(instance)
-
+	// Parse and validate request data
+	err := req.Bind(instance)
 	if err != nil {
-		log.Errorf("failed to read request body, err: %s\n", err.Error())
-		resp.Error(http.StatusBadRequest, "Bad Request")
+		log.Errorf("failed to bind request, err: %s\n", err.Error())
+		resp.BindError(err)
 		return
 	}
 
 	// Insert new data to database
-	count, err := base.session.Insert(instance)
+	count, err := session.Insert(instance)
 	if err != nil {
 		log.Errorf("failed to insert record, err: %s\n", err.Error())
 		resp.Error(http.StatusInternalServerError, "Internal Error")
@@ -87,7 +87,7 @@ func (base *BaseController) Create(resp *Response, req *Request) {
 func (base *BaseController) Retrive(resp *Response, req *Request) {
 	pk := req.Param("pk")
 	instance := reflect.New(reflect.Indirect(reflect.ValueOf(base.Model)).Type()).Interface()
-	err := base.session.FindOneWithPK(pk, instance)
+	err := base.session.WithContext(req.Context()).FindOneWithPK(pk, instance)
 	if err != nil {
 		resp.Error(http.StatusBadRequest, err.Error())
 		return
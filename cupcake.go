@@ -1,10 +1,16 @@
 package cupcake
 
 import (
+	"context"
 	"fmt"
 	"html/template"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 
+	"github.com/lz-nsc/cupcake/config"
+	"github.com/lz-nsc/cupcake/log"
 	"github.com/lz-nsc/cupcake/orm"
 	"github.com/lz-nsc/cupcake/orm/session"
 )
@@ -12,52 +18,226 @@ import (
 // cupcake request handler
 type HandlerFunc func(*Response, *Request)
 
-var defaultDBEngine *orm.ORMEngine
+var (
+	defaultDBEngine *orm.ORMEngine
+	// Database of the default ORM engine, can be changed by settings
+	defaultDBDriver = "sqlite3"
+	defaultDBSource = "cupcake.db"
+)
 
 type Cupcake struct {
 	*RouteGroup
 	router *router
 	groups []*RouteGroup
 	render *template.Template // for html render
+	// All registered routes in registration order
+	routes []*Route
+	// Routes registered with name, for url generation
+	namedRoutes map[string]*Route
+
+	// Serve HEAD requests with GET handler when no HEAD handler is registered
+	HandleHEAD bool
+	// Reply OPTIONS requests with allowed methods when no OPTIONS handler is registered
+	HandleOPTIONS bool
+	// Redirect to the path with or without trailing slash if only the other one is registered
+	RedirectTrailingSlash bool
+	// Redirect to the cleaned path if the path contains duplicate slashes or dot segments
+	RedirectFixedPath bool
+	// Match static segments of path case-insensitively, and redirect to the registered case
+	CaseInsensitive bool
+	// Buffer responses until handlers return, so middlewares can rewrite
+	// status, headers and body
+	BufferResponses bool
+
+	options    ServerOptions
+	settings   *config.Settings
+	server     *Server
+	serverOnce sync.Once
+
+	// Max size of multipart form kept in memory
+	multipartMemory int64
+	fileLimits      FileLimits
+	// Max size of decompressed request body, 0 means no limit
+	maxDecompressedSize int64
+
+	// Panic when a route conflicts with registered ones, otherwise the route
+	// is skipped and the error can be retrieved by RouteErrors
+	PanicOnConflict bool
+	routeErrors     []error
 }
 
 // Construct a new cupcake server
-func New() *Cupcake {
-	engine := &Cupcake{router: newRouter()}
+// cupcake.New()
+// cupcake.New(cupcake.WithReadTimeout(5*time.Second), cupcake.WithTLS("cert.pem", "key.pem"))
+func New(opts ...Option) *Cupcake {
+	engine := &Cupcake{
+		router:        newRouter(),
+		namedRoutes:   map[string]*Route{},
+		HandleHEAD:    true,
+		HandleOPTIONS: true,
+
+		multipartMemory:     defaultMultipartMemory,
+		maxDecompressedSize: defaultMaxDecompressedSize,
+
+		PanicOnConflict: true,
+	}
 	// Make the engine itself a group with empty prefix
 	engine.RouteGroup = NewGroup("", engine)
 	engine.groups = []*RouteGroup{engine.RouteGroup}
+	for _, opt := range opts {
+		opt(engine)
+	}
 	return engine
 }
 
-// Run a cupcake server
+// Run a cupcake server until it is shut down, returns error if the server
+// fails to listen or serve. The address in settings is used if no address
+// is given
 // cupcake.Run()
 // cupcake.Run(":80")
-// cupcake.Run("localhost")
 // cupcake.Run("127.0.0.1:8080")
-func (cc *Cupcake) Run(params ...string) {
+func (cc *Cupcake) Run(params ...string) error {
+	address := ""
+	if cc.settings != nil {
+		address = cc.settings.Addr
+	}
 	if len(params) > 0 {
 		// Only accept first param and discard the rest
-		cc.run(params[0])
+		address = params[0]
 	}
-	cc.run("")
+	return cc.Server().Run(address)
 }
 
-func (cc *Cupcake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	cc.handle(NewResponse(w, cc.render), NewRequest(r))
+// RunTLS runs a cupcake server with HTTPS
+func (cc *Cupcake) RunTLS(address string, certFile string, keyFile string) error {
+	return cc.Server().RunTLS(address, certFile, keyFile)
 }
 
-func (cc *Cupcake) run(address string) {
-	http.ListenAndServe(address, cc)
+// RunUnix runs a cupcake server on unix socket
+func (cc *Cupcake) RunUnix(path string) error {
+	return cc.Server().RunUnix(path)
 }
 
+// RunListener runs a cupcake server on given listener
+func (cc *Cupcake) RunListener(ln net.Listener) error {
+	return cc.Server().RunListener(ln)
+}
+
+// Shutdown shuts down the running server gracefully
+func (cc *Cupcake) Shutdown(ctx context.Context) error {
+	return cc.Server().Shutdown(ctx)
+}
+
+// Server returns the server which runs the engine
+func (cc *Cupcake) Server() *Server {
+	cc.serverOnce.Do(func() {
+		cc.server = NewServer(cc)
+	})
+	return cc.server
+}
+
+// RouteErrors returns errors of routes failed to register
+func (cc *Cupcake) RouteErrors() []error {
+	return cc.routeErrors
+}
+
+// Host returns a group bound to the host pattern, params in the pattern
+// like {tenant}.example.com can be retrieved by Request.Param
+func (cc *Cupcake) Host(pattern string) *RouteGroup {
+	group := NewGroup("", cc)
+	group.host = pattern
+	// Create radix tree for the host
+	cc.router.hostNode(group.host)
+	cc.groups = append(cc.groups, group)
+	return group
+}
+
+// errorHandler returns handler of the group with longest prefix matching the
+// request among groups which have set handler, groups bound to the host of
+// request win over others. The default handler is returned if there is none
+func (cc *Cupcake) errorHandler(req *Request, handlerOf func(*RouteGroup) HandlerFunc, defaultHandler HandlerFunc) HandlerFunc {
+	group := cc.matchGroup(req, func(g *RouteGroup) bool { return handlerOf(g) != nil })
+	if group == nil {
+		return defaultHandler
+	}
+	return handlerOf(group)
+}
+
+// matchGroup returns the accepted group with the longest prefix covering path
+// of the request, groups bound to the host of the request take precedence.
+// All groups are accepted if accept is nil
+func (cc *Cupcake) matchGroup(req *Request, accept func(*RouteGroup) bool) *RouteGroup {
+	var matched *RouteGroup
+	path := req.Path()
+	node, _ := cc.router.tree(req.Host())
+	bestScore := -1
+	for _, group := range cc.groups {
+		if accept != nil && !accept(group) {
+			continue
+		}
+		if path != group.prefix && !strings.HasPrefix(path, group.prefix+"/") {
+			continue
+		}
+		score := len(group.prefix) * 2
+		if group.host != "" {
+			if cc.router.hostNode(group.host) != node {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			matched = group
+			bestScore = score
+		}
+	}
+	return matched
+}
+
+func defaultNotFound(resp *Response, req *Request) {
+	log.Infof("%s : 404 NOT FOUND", req.String())
+	resp.Error(http.StatusNotFound, fmt.Sprintf("404 NOT FOUND: %s\n", req.Path()))
+}
+
+func defaultMethodNotAllowed(resp *Response, req *Request) {
+	log.Infof("%s : 405 Method Not Allowed", req.String())
+	resp.Error(http.StatusMethodNotAllowed, "405 Method Not Allowed")
+}
+
+func defaultRouterError(resp *Response, req *Request) {
+	err := req.RouteError()
+	if err == ErrNotImplemented {
+		log.Infof("%s : 501 Not Implemented", req.String())
+		resp.Error(http.StatusNotImplemented, "501 Not Implemented")
+		return
+	}
+	log.Errorf("Unknown error: %v", err)
+	resp.Error(http.StatusInternalServerError, fmt.Sprintf("Unknown error: %v\n", err))
+}
+
+func (cc *Cupcake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := NewRequest(r)
+	req.multipartMemory = cc.multipartMemory
+	req.fileLimits = cc.fileLimits
+	resp := NewResponse(w, cc.render)
+	resp.req = r
+	if cc.BufferResponses {
+		resp.Buffer()
+	}
+	cc.handle(resp, req)
+	resp.flush()
+}
+
+// LoadTemplates loads templates with given glob pattern, urls of named routes
+// can be generated in templates with {{ urlFor "user" "id" .ID }}
 func (cc *Cupcake) LoadTemplates(path string) {
-	cc.render = template.Must(template.New("").ParseGlob(path))
+	cc.render = template.Must(template.New("").Funcs(template.FuncMap{
+		"urlFor": cc.URLFor,
+	}).ParseGlob(path))
 }
 
 func newDBSession() *session.Session {
 	if defaultDBEngine == nil {
-		oe, err := orm.NewORMEngine("sqlite3", "cupcake.db")
+		oe, err := orm.NewORMEngine(defaultDBDriver, defaultDBSource)
 		if err != nil {
 			panic(fmt.Sprintf("Failed to create db engine, err: %s", err))
 		}
@@ -65,3 +245,10 @@ func newDBSession() *session.Session {
 	}
 	return defaultDBEngine.NewSession()
 }
+
+func closeDefaultDBEngine() {
+	if defaultDBEngine != nil {
+		defaultDBEngine.Close()
+		defaultDBEngine = nil
+	}
+}
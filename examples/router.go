@@ -19,9 +19,9 @@ func main() {
 		resp.String(http.StatusOK, fmt.Sprintf("Welcome to tea id[%s]!", id))
 	})
 
-	cc.GET("/cupcake/{name:[a-z]+}", func(resp *cupcake.Response, req *cupcake.Request) {
+	cc.GET("/cake/{name:[a-z]+}", func(resp *cupcake.Response, req *cupcake.Request) {
 		name := req.Param("name")
-		resp.String(http.StatusOK, fmt.Sprintf("Welcome to cupcake name[%s]!", name))
+		resp.String(http.StatusOK, fmt.Sprintf("Welcome to cake name[%s]!", name))
 	})
 	cc.GET("/cupcake/{id}/*", func(resp *cupcake.Response, req *cupcake.Request) {
 		id := req.Param("id")
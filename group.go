@@ -1,9 +1,10 @@
 package cupcake
 
 import (
-	"fmt"
 	"net/http"
+	"net/url"
 	"path"
+	"strings"
 
 	"github.com/lz-nsc/cupcake/log"
 )
@@ -12,6 +13,19 @@ type RouteGroup struct {
 	prefix      string
 	middlewares []MiddlerWare
 	engine      *Cupcake
+	// Middlewares of parent group also apply to this group
+	parent *RouteGroup
+	// Host pattern the group is bound to, empty means any host
+	host string
+
+	// Handlers for requests failed to route, nil means inheriting from
+	// the group with shorter prefix
+	notFound         HandlerFunc
+	methodNotAllowed HandlerFunc
+	routerError      HandlerFunc
+
+	// Max size of request body, 0 means inheriting from parent group
+	maxBodySize int64
 }
 
 type MiddlerWare func(HandlerFunc) HandlerFunc
@@ -24,6 +38,7 @@ func NewGroup(prefix string, engine *Cupcake) *RouteGroup {
 	return &RouteGroup{
 		prefix: prefix,
 		engine: engine,
+		parent: engine.RouteGroup,
 	}
 }
 func (group *RouteGroup) Group(prefix string) *RouteGroup {
@@ -31,14 +46,35 @@ func (group *RouteGroup) Group(prefix string) *RouteGroup {
 	child := &RouteGroup{
 		prefix: group.prefix + prefix,
 		engine: engine,
+		host:   group.host,
+		parent: group,
 	}
 
 	engine.groups = append(engine.groups, child)
 	return child
 }
 
-func (group *RouteGroup) MiddlerWare(m MiddlerWare) {
-	group.middlewares = append(group.middlewares, m)
+// NotFound sets handler for requests whose path is not found under this group
+func (group *RouteGroup) NotFound(handler HandlerFunc) {
+	group.notFound = handler
+}
+
+// MethodNotAllowed sets handler for requests whose method is not allowed
+// under this group, the Allow header is set before the handler is called
+func (group *RouteGroup) MethodNotAllowed(handler HandlerFunc) {
+	group.methodNotAllowed = handler
+}
+
+// RouterError sets handler for other errors occurred when routing requests
+// under this group, the error can be retrieved by Request.RouteError
+func (group *RouteGroup) RouterError(handler HandlerFunc) {
+	group.routerError = handler
+}
+
+// MiddlerWare adds middlewares to the group, middlewares added first are
+// the outer ones
+func (group *RouteGroup) MiddlerWare(middlewares ...MiddlerWare) {
+	group.middlewares = append(group.middlewares, middlewares...)
 }
 
 func (group *RouteGroup) Static(pattern string, folder string) {
@@ -63,53 +99,189 @@ func (group *RouteGroup) staticHandler(path string, fs http.FileSystem) HandlerF
 			return
 		}
 
-		fileServer.ServeHTTP(resp.writer, req.req)
+		fileServer.ServeHTTP(resp.Writer(), req.req)
 	}
 }
 
-func (group *RouteGroup) addRouter(method methodType, path string, handler HandlerFunc) {
+func (group *RouteGroup) addRouter(method methodType, path string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
 	if path[0] != '/' {
 		path = "/" + path
 	}
-	pattern := group.prefix + path
-	handler = group.wrapMiddlewares(handler)
-	group.engine.router.addRouter(method, pattern, handler)
+	route := &Route{
+		method:  method,
+		host:    group.host,
+		pattern: group.prefix + path,
+		handler: handler,
+		group:   group,
+
+		middlewares: middlewares,
+	}
+	engine := group.engine
+	if err := engine.router.addRouter(group.host, method, route.pattern, route.serve); err != nil {
+		if engine.PanicOnConflict {
+			panic(err.Error())
+		}
+		log.Error(err)
+		engine.routeErrors = append(engine.routeErrors, err)
+		return route
+	}
+	engine.routes = append(engine.routes, route)
+	return route
 }
 
 func (group *RouteGroup) handle(resp *Response, req *Request) {
-	handler, err := group.engine.router.handler(resp, req)
+	engine := group.engine
+	handler, err := engine.router.handler(resp, req)
+	if err == ErrNotAllow {
+		handler, err = group.autoHandler(resp, req)
+	}
+	if err == ErrNotFound && group.redirect(resp, req) {
+		return
+	}
 	if err != nil {
+		req.routeErr = err
 		switch err {
 		case ErrNotAllow:
-			fmt.Printf("%s : 405 Method Not Allowed\n", req.String())
-			resp.Error(http.StatusMethodNotAllowed, "405 Method Not Allowed")
+			resp.SetHeader("Allow", group.allowHeader(req))
+			engine.errorHandler(req, func(g *RouteGroup) HandlerFunc { return g.methodNotAllowed }, defaultMethodNotAllowed)(resp, req)
 		case ErrNotFound:
-			fmt.Printf("%s : 404 NOT FOUND\n", req.String())
-			resp.Error(http.StatusNotFound, fmt.Sprintf("404 NOT FOUND: %s\n", req.Path()))
+			engine.errorHandler(req, func(g *RouteGroup) HandlerFunc { return g.notFound }, defaultNotFound)(resp, req)
 		default:
-			fmt.Printf("Unknown error: %s\n", err.Error())
-			resp.Error(http.StatusInternalServerError, fmt.Sprintf("Unknown error: %s\n", err.Error()))
+			engine.errorHandler(req, func(g *RouteGroup) HandlerFunc { return g.routerError }, defaultRouterError)(resp, req)
 		}
 		return
 	}
 
 	log.Info(req.String())
-	req.readData()
 	handler(resp, req)
 }
 
-func (group *RouteGroup) GET(pattern string, handler HandlerFunc) {
-	group.addRouter(GET, pattern, handler)
+// redirect redirects request to the canonical form of its path,
+// returns false if no registered path is found
+func (group *RouteGroup) redirect(resp *Response, req *Request) bool {
+	engine := group.engine
+	if !engine.RedirectTrailingSlash && !engine.RedirectFixedPath && !engine.CaseInsensitive {
+		return false
+	}
+	path, ok := engine.router.canonicalPath(req, engine.RedirectTrailingSlash, engine.RedirectFixedPath, engine.CaseInsensitive)
+	if !ok {
+		return false
+	}
+
+	code := http.StatusMovedPermanently
+	if req.Method() != http.MethodGet && req.Method() != http.MethodHead {
+		// Keep method and body for other methods
+		code = http.StatusPermanentRedirect
+	}
+	location := (&url.URL{Path: req.mountPrefix + path, RawQuery: req.req.URL.RawQuery}).String()
+	log.Infof("%s : %d redirect to %s", req.String(), code, location)
+	resp.Redirect(code, location)
+	return true
 }
 
-func (group *RouteGroup) POST(pattern string, handler HandlerFunc) {
-	group.addRouter(POST, pattern, handler)
+// autoHandler generates handler for HEAD and OPTIONS requests which have no
+// handler registered explicitly, middlewares of the group matching the path
+// apply to both of them
+func (group *RouteGroup) autoHandler(resp *Response, req *Request) (HandlerFunc, error) {
+	engine := group.engine
+	switch req.Method() {
+	case http.MethodHead:
+		if !engine.HandleHEAD {
+			break
+		}
+		handler, err := engine.router.route(req, GET)
+		if err != nil {
+			return nil, err
+		}
+		// Run GET handler and discard the body
+		resp.writer = &headWriter{resp.writer}
+		return handler, nil
+	case http.MethodOptions:
+		if !engine.HandleOPTIONS {
+			break
+		}
+		allow := group.allowHeader(req)
+		var handler HandlerFunc = func(resp *Response, req *Request) {
+			resp.SetHeader("Allow", allow)
+			resp.Status(http.StatusNoContent)
+		}
+		if matched := engine.matchGroup(req, nil); matched != nil {
+			handler = matched.middlewareChain().Then(handler)
+		}
+		return handler, nil
+	}
+	return nil, ErrNotAllow
+}
+
+// allowHeader returns value of Allow header for path of the request
+func (group *RouteGroup) allowHeader(req *Request) string {
+	engine := group.engine
+	methods := engine.router.allowed(req)
+	names := make([]string, 0, len(methods)+2)
+	hasHead, hasOptions := false, false
+	for _, method := range methods {
+		switch method {
+		case HEAD:
+			hasHead = true
+		case OPTIONS:
+			hasOptions = true
+		}
+	}
+	for _, method := range methods {
+		names = append(names, method.String())
+		if method == GET && !hasHead && engine.HandleHEAD {
+			names = append(names, http.MethodHead)
+		}
+	}
+	if !hasOptions && engine.HandleOPTIONS {
+		names = append(names, http.MethodOptions)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (group *RouteGroup) GET(pattern string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
+	return group.addRouter(GET, pattern, handler, middlewares...)
 }
-func (group *RouteGroup) PUT(pattern string, handler HandlerFunc) {
-	group.addRouter(PUT, pattern, handler)
+
+func (group *RouteGroup) POST(pattern string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
+	return group.addRouter(POST, pattern, handler, middlewares...)
+}
+func (group *RouteGroup) PUT(pattern string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
+	return group.addRouter(PUT, pattern, handler, middlewares...)
+}
+func (group *RouteGroup) DELETE(pattern string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
+	return group.addRouter(DELETE, pattern, handler, middlewares...)
+}
+func (group *RouteGroup) PATCH(pattern string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
+	return group.addRouter(PATCH, pattern, handler, middlewares...)
+}
+func (group *RouteGroup) HEAD(pattern string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
+	return group.addRouter(HEAD, pattern, handler, middlewares...)
+}
+func (group *RouteGroup) OPTIONS(pattern string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
+	return group.addRouter(OPTIONS, pattern, handler, middlewares...)
+}
+func (group *RouteGroup) CONNECT(pattern string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
+	return group.addRouter(CONNECT, pattern, handler, middlewares...)
 }
-func (group *RouteGroup) DELETE(pattern string, handler HandlerFunc) {
-	group.addRouter(DELETE, pattern, handler)
+func (group *RouteGroup) TRACE(pattern string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
+	return group.addRouter(TRACE, pattern, handler, middlewares...)
+}
+
+// Handle registers handler with any HTTP method, including custom methods
+// such as PROPFIND or PURGE
+func (group *RouteGroup) Handle(method string, pattern string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
+	return group.addRouter(registerMethod(method), pattern, handler, middlewares...)
+}
+
+// Any registers handler with all standard HTTP methods, the returned route
+// can be used to name the pattern
+func (group *RouteGroup) Any(pattern string, handler HandlerFunc, middlewares ...MiddlerWare) *Route {
+	var route *Route
+	for _, method := range standardMethods {
+		route = group.addRouter(method, pattern, handler, middlewares...)
+	}
+	return route
 }
 
 func (group *RouteGroup) Route(pattern string, controller Controller) {
@@ -120,12 +292,19 @@ func (group *RouteGroup) Route(pattern string, controller Controller) {
 	group.addRouter(GET, idPattern, controller.Retrive)
 	group.addRouter(POST, pattern, controller.Create)
 	group.addRouter(PUT, idPattern, controller.Update)
-	group.addRouter(PUT, idPattern, controller.Delete)
+	group.addRouter(DELETE, idPattern, controller.Delete)
 }
 
-func (group *RouteGroup) wrapMiddlewares(handler HandlerFunc) HandlerFunc {
-	for _, middlerWare := range group.middlewares {
-		handler = middlerWare(handler)
+// middlewareChain returns middlewares applied to the group, middlewares of
+// the engine are the outermost
+func (group *RouteGroup) middlewareChain() Chain {
+	groups := []*RouteGroup{}
+	for g := group; g != nil; g = g.parent {
+		groups = append(groups, g)
+	}
+	chain := Chain{}
+	for idx := len(groups) - 1; idx >= 0; idx-- {
+		chain = chain.Append(groups[idx].middlewares...)
 	}
-	return handler
+	return chain
 }
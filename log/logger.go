@@ -1,9 +1,11 @@
 package log
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 	"sync"
 )
 
@@ -31,6 +33,21 @@ var (
 	Infof  = infoLog.Printf
 )
 
+// ParseLevel parses level name: debug, info, error or disable
+func ParseLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return DEBUG, nil
+	case "info":
+		return INFO, nil
+	case "error":
+		return ERROR, nil
+	case "disable":
+		return DISABLE, nil
+	}
+	return DISABLE, fmt.Errorf("invalid log level '%s'", name)
+}
+
 func SetLevel(lv LogLevel) {
 	mu.Lock()
 	defer mu.Unlock()
@@ -0,0 +1,270 @@
+package middlewares
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lz-nsc/cupcake"
+)
+
+func init() {
+	cupcake.RegisterMiddleware("compress", Compress)
+}
+
+// CompressOptions configures the compression middleware
+type CompressOptions struct {
+	// Compression level of gzip and deflate, 0 means the default level
+	Level int
+	// Responses smaller than MinSize are not compressed
+	MinSize int
+	// Media types to compress, such as "application/json" or "text/*"
+	ContentTypes []string
+}
+
+var DefaultCompressOptions = CompressOptions{
+	Level:   gzip.DefaultCompression,
+	MinSize: 1024,
+	ContentTypes: []string{
+		"text/*",
+		"application/json",
+		"application/xml",
+		"application/javascript",
+		"application/x-yaml",
+		"application/x-ndjson",
+		"image/svg+xml",
+	},
+}
+
+// Compress compresses responses with gzip or deflate according to the
+// Accept-Encoding header, with DefaultCompressOptions
+func Compress(handler cupcake.HandlerFunc) cupcake.HandlerFunc {
+	return NewCompress(DefaultCompressOptions)(handler)
+}
+
+// NewCompress returns compression middleware with given options
+func NewCompress(opts CompressOptions) cupcake.MiddlerWare {
+	if opts.Level == 0 {
+		opts.Level = gzip.DefaultCompression
+	}
+	return func(handler cupcake.HandlerFunc) cupcake.HandlerFunc {
+		return cupcake.HandlerFunc(func(resp *cupcake.Response, req *cupcake.Request) {
+			resp.AddVary("Accept-Encoding")
+			encoding := acceptEncoding(req.Header("Accept-Encoding"))
+			if encoding == "" {
+				handler(resp, req)
+				return
+			}
+
+			cw := &compressWriter{opts: opts, encoding: encoding}
+			cw.ResponseWriter = resp.SetWriter(cw)
+			returned := false
+			// Writer is restored even if the handler panics, so that
+			// outer middlewares like Recovery can respond
+			defer func() {
+				resp.SetWriter(cw.ResponseWriter)
+				if !resp.Buffered() {
+					cw.Close()
+				} else if returned {
+					// Body is kept in response until it is flushed
+					compressBody(resp, opts, encoding)
+				}
+			}()
+			handler(resp, req)
+			returned = true
+		})
+	}
+}
+
+// acceptEncoding returns the supported encoding with highest quality,
+// gzip is preferred if qualities are equal
+func acceptEncoding(header string) string {
+	best, bestQ := "", 0.0
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		qualities[coding] = q
+	}
+	for _, coding := range []string{"gzip", "deflate"} {
+		q, ok := qualities[coding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether response with given status and header can
+// be compressed
+func compressible(opts CompressOptions, status int, header http.Header, body []byte) bool {
+	if status < http.StatusOK || status == http.StatusNoContent ||
+		status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+		header.Set("Content-Type", contentType)
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	for _, allowed := range opts.ContentTypes {
+		if allowed == mediaType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, allowed[:len(allowed)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func newEncoder(w io.Writer, encoding string, level int) (io.WriteCloser, error) {
+	if encoding == "gzip" {
+		return gzip.NewWriterLevel(w, level)
+	}
+	return zlib.NewWriterLevel(w, level)
+}
+
+// compressBody compresses body of buffered response
+func compressBody(resp *cupcake.Response, opts CompressOptions, encoding string) {
+	body := resp.Body()
+	if len(body) < opts.MinSize || !compressible(opts, resp.StatusCode(), resp.Header(), body) {
+		return
+	}
+	var buffer bytes.Buffer
+	encoder, err := newEncoder(&buffer, encoding, opts.Level)
+	if err != nil {
+		return
+	}
+	if _, err := encoder.Write(body); err != nil {
+		return
+	}
+	if err := encoder.Close(); err != nil {
+		return
+	}
+	resp.Header().Del("Content-Length")
+	resp.Header().Set("Content-Encoding", encoding)
+	resp.SetBody(buffer.Bytes())
+}
+
+// compressWriter holds body until MinSize is reached to decide whether to
+// compress it, flushing decides immediately for streaming responses
+type compressWriter struct {
+	http.ResponseWriter
+	opts     CompressOptions
+	encoding string
+
+	status  int
+	pending []byte
+	decided bool
+	encoder io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		return w.write(b)
+	}
+	w.pending = append(w.pending, b...)
+	if len(w.pending) >= w.opts.MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) write(b []byte) (int, error) {
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide writes header and pending body, the body is compressed if it is
+// large enough and its type is allowed
+func (w *compressWriter) decide(large bool) error {
+	w.decided = true
+	if w.status == 0 {
+		if len(w.pending) == 0 {
+			return nil
+		}
+		w.status = http.StatusOK
+	}
+	header := w.Header()
+	if large && compressible(w.opts, w.status, header, w.pending) {
+		encoder, err := newEncoder(w.ResponseWriter, w.encoding, w.opts.Level)
+		if err != nil {
+			return err
+		}
+		w.encoder = encoder
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	pending := w.pending
+	w.pending = nil
+	_, err := w.write(pending)
+	return err
+}
+
+// Flush sends compressed data to client, so streaming responses are
+// compressed regardless of MinSize
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets websocket handlers take over the connection
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if err := w.decide(len(w.pending) >= w.opts.MinSize); err != nil {
+			return err
+		}
+	}
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lz-nsc/cupcake"
+	"github.com/lz-nsc/cupcake/log"
+)
+
+func init() {
+	log.SetLevel(log.DISABLE)
+}
+
+func TestCompressRecoversPanic(t *testing.T) {
+	for _, buffered := range []bool{false, true} {
+		cc := cupcake.New()
+		cc.BufferResponses = buffered
+		cc.MiddlerWare(Recovery, Compress)
+		cc.GET("/panic", func(resp *cupcake.Response, req *cupcake.Request) {
+			panic("boom")
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "/panic", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		cc.ServeHTTP(w, r)
+		if w.Code != http.StatusInternalServerError || w.Body.String() != "Internal Server Error\n" {
+			t.Errorf("buffered %v: got %d %q", buffered, w.Code, w.Body.String())
+		}
+		if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+			t.Errorf("buffered %v: got Content-Encoding %q", buffered, encoding)
+		}
+	}
+}
+
+func TestCompressEventStream(t *testing.T) {
+	cc := cupcake.New()
+	sizes := make(chan int, 1)
+	cc.MiddlerWare(func(handler cupcake.HandlerFunc) cupcake.HandlerFunc {
+		return func(resp *cupcake.Response, req *cupcake.Request) {
+			handler(resp, req)
+			// Heartbeats must have stopped when the handler returns
+			size := resp.Size()
+			time.Sleep(20 * time.Millisecond)
+			if resp.Size() != size {
+				t.Error("event stream is written after the handler returns")
+			}
+			sizes <- size
+		}
+	}, Compress)
+	cc.GET("/events", func(resp *cupcake.Response, req *cupcake.Request) {
+		stream, err := resp.SSE()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		stream.Heartbeat(time.Millisecond)
+		stream.Send(cupcake.Event{Data: "hello"})
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	cc.ServeHTTP(w, r)
+	<-sizes
+	if w.Header().Get("Content-Encoding") != "gzip" || !w.Flushed {
+		t.Fatalf("got header %v, flushed %v", w.Header(), w.Flushed)
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(body), "data: hello\n\n") {
+		t.Errorf("got %q", body)
+	}
+}
+
+func decompress(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got Content-Encoding %q", w.Header().Get("Content-Encoding"))
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestCompressStreamingWriter(t *testing.T) {
+	cc := cupcake.New()
+	cc.MiddlerWare(Compress)
+	flushed := make(chan bool, 1)
+	cc.GET("/stream", func(resp *cupcake.Response, req *cupcake.Request) {
+		resp.SetHeader("Content-Type", "text/plain")
+		w := resp.Writer()
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Error("writer is not a http.Flusher")
+			return
+		}
+		// Chunks smaller than MinSize are still compressed once flushed
+		w.Write([]byte("chunk 1\n"))
+		flusher.Flush()
+		flushed <- resp.Written()
+		w.Write([]byte("chunk 2\n"))
+		flusher.Flush()
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	cc.ServeHTTP(w, r)
+	if !<-flushed || !w.Flushed {
+		t.Error("response is not flushed")
+	}
+	if w.Header().Get("Content-Length") != "" {
+		t.Errorf("got Content-Length %q", w.Header().Get("Content-Length"))
+	}
+	if body := decompress(t, w); body != "chunk 1\nchunk 2\n" {
+		t.Errorf("got %q", body)
+	}
+}
+
+func TestCompressStaticFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	content := strings.Repeat("body { color: red; }\n", 100)
+	if err := ioutil.WriteFile(filepath.Join(dir, "site.css"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cc := cupcake.New()
+	cc.MiddlerWare(Compress)
+	cc.Static("/assets", dir)
+
+	r := httptest.NewRequest(http.MethodGet, "/assets/site.css", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	cc.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d", w.Code)
+	}
+	if length := w.Header().Get("Content-Length"); length != "" {
+		t.Errorf("got Content-Length %q of uncompressed file", length)
+	}
+	if body := decompress(t, w); body != content {
+		t.Errorf("got %d bytes, want %d", len(body), len(content))
+	}
+
+	// Ranges of files are left uncompressed
+	r = httptest.NewRequest(http.MethodGet, "/assets/site.css", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("Range", "bytes=0-3")
+	w = httptest.NewRecorder()
+	cc.ServeHTTP(w, r)
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Encoding") != "" || w.Body.String() != "body" {
+		t.Errorf("got %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestCompressVary(t *testing.T) {
+	cc := cupcake.New()
+	cc.MiddlerWare(Compress, Compress)
+	cc.GET("/users", func(resp *cupcake.Response, req *cupcake.Request) {
+		resp.AddVary("accept-encoding")
+		resp.Negotiate(http.StatusOK, map[string]string{"name": "cupcake"})
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	w := httptest.NewRecorder()
+	cc.ServeHTTP(w, r)
+	if vary := w.Header().Values("Vary"); strings.Join(vary, ",") != "Accept-Encoding,Accept" {
+		t.Errorf("got Vary %v", vary)
+	}
+}
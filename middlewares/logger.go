@@ -7,6 +7,10 @@ import (
 	"github.com/lz-nsc/cupcake/log"
 )
 
+func init() {
+	cupcake.RegisterMiddleware("logger", Logger)
+}
+
 func Logger(handler cupcake.HandlerFunc) cupcake.HandlerFunc {
 	return cupcake.HandlerFunc(func(resp *cupcake.Response, req *cupcake.Request) {
 		t := time.Now()
@@ -10,6 +10,10 @@ import (
 	"github.com/lz-nsc/cupcake/log"
 )
 
+func init() {
+	cupcake.RegisterMiddleware("recovery", Recovery)
+}
+
 func trace(message string) string {
 	var pcs [32]uintptr
 	//Skip first three callers
@@ -0,0 +1,88 @@
+package cupcake
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type paramsKey struct{}
+
+// Mount mounts a http.Handler or a *Cupcake under prefix. The prefix is
+// stripped from the path before the request is passed to the handler, and
+// middlewares of the group still apply. Requests of every method are passed
+// to the handler, including custom methods such as PROPFIND. Params in prefix can be retrieved by
+// PathParams in http.Handler, or by Request.Param in the mounted engine
+func (group *RouteGroup) Mount(prefix string, handler http.Handler, middlewares ...MiddlerWare) {
+	if prefix == "" || prefix[0] != '/' {
+		prefix = "/" + prefix
+	}
+	prefix = strings.TrimRight(prefix, "/")
+
+	mounted := mountHandler(handler)
+	if prefix != "" {
+		group.addRouter(anyMethod, prefix, mounted, middlewares...)
+	}
+	group.addRouter(anyMethod, prefix+"/", mounted, middlewares...)
+	group.addRouter(anyMethod, prefix+"/*", mounted, middlewares...)
+}
+
+// PathParams returns params matched by cupcake for request passed to
+// a mounted http.Handler
+func PathParams(r *http.Request) map[string]string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params
+}
+
+func mountHandler(handler http.Handler) HandlerFunc {
+	if sub, ok := handler.(*Cupcake); ok {
+		return func(resp *Response, req *Request) {
+			stripped := stripRequest(req)
+			defer removeMultipartForm(stripped, req.req)
+			subReq := NewRequest(stripped)
+			for key, val := range req.Params() {
+				subReq.params[key] = val
+			}
+			// Redirects of the mounted engine keep the prefix
+			subReq.mountPrefix = req.mountPrefix + strings.TrimSuffix(strings.TrimSuffix(req.Path(), req.Wild()), "/")
+			// Body is already limited and decompressed by this engine
+			subReq.body = req.body
+			subReq.multipartMemory = sub.multipartMemory
+			subReq.fileLimits = sub.fileLimits
+			// Render with templates of the mounted engine
+			render := resp.render
+			resp.render = sub.render
+			defer func() {
+				resp.render = render
+			}()
+			sub.handle(resp, subReq)
+		}
+	}
+	return func(resp *Response, req *Request) {
+		stripped := stripRequest(req)
+		defer removeMultipartForm(stripped, req.req)
+		handler.ServeHTTP(resp.Writer(), stripped)
+	}
+}
+
+// removeMultipartForm removes temporary files of multipart form parsed by
+// the mounted handler, as net/http only removes those of the original request
+func removeMultipartForm(stripped *http.Request, original *http.Request) {
+	if form := stripped.MultipartForm; form != nil && form != original.MultipartForm {
+		form.RemoveAll()
+	}
+}
+
+// stripRequest returns shallow copy of the http.Request with the wild part
+// as path and params stored in its context
+func stripRequest(req *Request) *http.Request {
+	r := new(http.Request)
+	*r = *req.req
+	u := new(url.URL)
+	*u = *req.req.URL
+	u.Path = "/" + req.Wild()
+	u.RawPath = ""
+	r.URL = u
+	return r.WithContext(context.WithValue(r.Context(), paramsKey{}, req.Params()))
+}
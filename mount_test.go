@@ -0,0 +1,76 @@
+package cupcake
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMountRedirect(t *testing.T) {
+	ok := func(resp *Response, req *Request) { resp.String(http.StatusOK, "ok") }
+	sub := New()
+	sub.RedirectTrailingSlash = true
+	sub.RedirectFixedPath = true
+	sub.GET("/p/{id}", ok)
+	nested := New()
+	nested.RedirectTrailingSlash = true
+	nested.GET("/q/", ok)
+	sub.Mount("/n", nested)
+
+	cc := New()
+	cc.Mount("/s", sub)
+
+	tests := []struct {
+		target   string
+		location string
+	}{
+		{"/s/p/5/", "/s/p/5"},
+		{"/s//p/5", "/s/p/5"},
+		{"/s/n/q", "/s/n/q/"},
+	}
+	for _, test := range tests {
+		w := serve(cc, http.MethodGet, test.target)
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("%s: got %d, want %d", test.target, w.Code, http.StatusMovedPermanently)
+		}
+		if location := w.Header().Get("Location"); location != test.location {
+			t.Errorf("%s: got Location %q, want %q", test.target, location, test.location)
+		}
+	}
+}
+
+func TestMountAnyMethod(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.URL.Path))
+	})
+	cc := New()
+	cc.Mount("/dav", handler)
+	cc.Handle("REPORT", "/other", func(resp *Response, req *Request) {})
+
+	tests := []struct {
+		method string
+		target string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "/dav/a", http.StatusOK, "GET /a"},
+		{http.MethodDelete, "/dav", http.StatusOK, "DELETE /"},
+		{"PROPFIND", "/dav/a", http.StatusOK, "PROPFIND /a"},
+		{"REPORT", "/dav/a/b", http.StatusOK, "REPORT /a/b"},
+		{"PROPFIND", "/other", http.StatusNotImplemented, ""},
+	}
+	for _, test := range tests {
+		w := serve(cc, test.method, test.target)
+		if w.Code != test.code {
+			t.Errorf("%s %s: got %d, want %d", test.method, test.target, w.Code, test.code)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %s: got %q, want %q", test.method, test.target, w.Body.String(), test.body)
+		}
+	}
+
+	// Methods registered after mounting are passed to the mounted handler too
+	cc.Handle("MKCALENDAR", "/cal", func(resp *Response, req *Request) {})
+	if w := serve(cc, "MKCALENDAR", "/dav/c"); w.Body.String() != "MKCALENDAR /c" {
+		t.Errorf("MKCALENDAR /dav/c: got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,151 @@
+package cupcake
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrNotAcceptable = errors.New("not acceptable")
+
+// Media types offered by Negotiate in order of preference, other registered
+// codecs are offered after them
+var preferredMediaTypes = []string{ApplicationJSON, ApplicationXML, ApplicationYAML, ApplicationProto, TextXML}
+
+// acceptRange is a media range in Accept header
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// Negotiate responds obj encoded in the media type preferred by the Accept
+// header of request, JSON is used if there is no Accept header. Less
+// preferred media types are tried if obj fails to be encoded, and 500 is
+// responded if all of them fail. 406 is responded and ErrNotAcceptable is
+// returned if no codec is acceptable
+func (resp *Response) Negotiate(code int, obj interface{}) error {
+	resp.AddVary("Accept")
+	accept := ""
+	if resp.req != nil {
+		accept = resp.req.Header.Get("Accept")
+	}
+	mediaTypes := negotiate(accept, offeredMediaTypes(obj))
+	if len(mediaTypes) == 0 {
+		resp.Error(http.StatusNotAcceptable, http.StatusText(http.StatusNotAcceptable))
+		return ErrNotAcceptable
+	}
+	var err error
+	for _, mediaType := range mediaTypes {
+		if err = resp.Encode(code, mediaType, obj); err == nil {
+			return nil
+		}
+	}
+	resp.Error(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	return err
+}
+
+// offeredMediaTypes returns media types of registered codecs in order of
+// preference, protobuf is offered only for proto messages
+func offeredMediaTypes(obj interface{}) []string {
+	_, isProto := obj.(proto.Message)
+	codecMu.RLock()
+	others := make([]string, 0, len(codecs))
+	for mediaType := range codecs {
+		others = append(others, mediaType)
+	}
+	codecMu.RUnlock()
+	sort.Strings(others)
+
+	offers := []string{}
+	seen := map[string]bool{}
+	for _, mediaType := range append(preferredMediaTypes, others...) {
+		if seen[mediaType] || (mediaType == ApplicationProto && !isProto) {
+			continue
+		}
+		if _, ok := getCodec(mediaType); !ok {
+			continue
+		}
+		seen[mediaType] = true
+		offers = append(offers, mediaType)
+	}
+	return offers
+}
+
+// negotiate returns offers acceptable by accept in descending order of
+// quality, the earlier offer wins if qualities are equal
+func negotiate(accept string, offers []string) []string {
+	if strings.TrimSpace(accept) == "" {
+		return offers
+	}
+	ranges := parseAccept(accept)
+	acceptable := []string{}
+	qualities := map[string]float64{}
+	for _, offer := range offers {
+		if q := acceptQuality(ranges, offer); q > 0 {
+			acceptable = append(acceptable, offer)
+			qualities[offer] = q
+		}
+	}
+	sort.SliceStable(acceptable, func(i, j int) bool {
+		return qualities[acceptable[i]] > qualities[acceptable[j]]
+	})
+	return acceptable
+}
+
+func parseAccept(accept string) []acceptRange {
+	ranges := []acceptRange{}
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+		if mediaType == "*" {
+			mediaType = "*/*"
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			key, val, found := cutString(strings.TrimSpace(param), "=")
+			if !found || !strings.EqualFold(key, "q") {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(val, 64); err == nil && parsed >= 0 && parsed <= 1 {
+				q = parsed
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// acceptQuality returns quality of the most specific range matching the media type
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	q, specificity := 0.0, -1
+	typ, _, _ := cutString(mediaType, "/")
+	for _, r := range ranges {
+		current := -1
+		switch {
+		case r.mediaType == mediaType:
+			current = 2
+		case r.mediaType == typ+"/*":
+			current = 1
+		case r.mediaType == "*/*":
+			current = 0
+		}
+		if current > specificity {
+			q, specificity = r.q, current
+		}
+	}
+	return q
+}
+
+func cutString(s string, sep string) (before string, after string, found bool) {
+	if idx := strings.Index(s, sep); idx >= 0 {
+		return s[:idx], s[idx+len(sep):], true
+	}
+	return s, "", false
+}
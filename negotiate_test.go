@@ -0,0 +1,43 @@
+package cupcake
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type failingCodec struct{}
+
+func (failingCodec) Decode(r io.Reader, obj interface{}) error { return errors.New("decode") }
+func (failingCodec) Encode(w io.Writer, obj interface{}) error { return errors.New("encode") }
+
+func TestNegotiateFallback(t *testing.T) {
+	RegisterCodec("application/x-failing", failingCodec{})
+	cc := New()
+	cc.GET("/item", func(resp *Response, req *Request) {
+		resp.Negotiate(http.StatusOK, map[string]interface{}{"name": "a"})
+	})
+
+	tests := []struct {
+		accept      string
+		code        int
+		contentType string
+	}{
+		// xml can not encode maps
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", http.StatusOK, ApplicationJSON},
+		{"application/xml", http.StatusInternalServerError, "text/plain; charset=utf-8"},
+		{"application/x-failing, application/x-yaml;q=0.5", http.StatusOK, ApplicationYAML},
+		{"image/png", http.StatusNotAcceptable, "text/plain; charset=utf-8"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/item", nil)
+		r.Header.Set("Accept", test.accept)
+		w := httptest.NewRecorder()
+		cc.ServeHTTP(w, r)
+		if w.Code != test.code || w.Header().Get("Content-Type") != test.contentType {
+			t.Errorf("%s: got %d %q, want %d %q", test.accept, w.Code, w.Header().Get("Content-Type"), test.code, test.contentType)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package cupcake
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// ServerOptions are applied to the http.Server running the engine
+type ServerOptions struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	TLSConfig         *tls.Config
+	// Cert and key files for serving HTTPS, Run serves HTTPS if they are set
+	CertFile string
+	KeyFile  string
+}
+
+// Option configures the engine when it is created by New
+type Option func(*Cupcake)
+
+func WithServerOptions(opts ServerOptions) Option {
+	return func(cc *Cupcake) {
+		cc.options = opts
+	}
+}
+
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(cc *Cupcake) {
+		cc.options.ReadTimeout = timeout
+	}
+}
+
+func WithReadHeaderTimeout(timeout time.Duration) Option {
+	return func(cc *Cupcake) {
+		cc.options.ReadHeaderTimeout = timeout
+	}
+}
+
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(cc *Cupcake) {
+		cc.options.WriteTimeout = timeout
+	}
+}
+
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(cc *Cupcake) {
+		cc.options.IdleTimeout = timeout
+	}
+}
+
+func WithMaxHeaderBytes(size int) Option {
+	return func(cc *Cupcake) {
+		cc.options.MaxHeaderBytes = size
+	}
+}
+
+// WithTLS makes Run serve HTTPS with given cert and key files
+func WithTLS(certFile string, keyFile string) Option {
+	return func(cc *Cupcake) {
+		cc.options.CertFile = certFile
+		cc.options.KeyFile = keyFile
+	}
+}
+
+// WithTLSConfig sets tls.Config of the server, certificates in the config
+// are used if no cert file is given
+func WithTLSConfig(config *tls.Config) Option {
+	return func(cc *Cupcake) {
+		cc.options.TLSConfig = config
+	}
+}
+
+// WithMultipartMemory sets max size of multipart form kept in memory, the
+// rest of uploaded files are stored in temporary files on disk
+func WithMultipartMemory(size int64) Option {
+	return func(cc *Cupcake) {
+		cc.multipartMemory = size
+	}
+}
+
+// WithFileLimits sets limits of each uploaded file
+func WithFileLimits(limits FileLimits) Option {
+	return func(cc *Cupcake) {
+		cc.fileLimits = limits
+	}
+}
+
+// WithMaxBodySize sets max size of request body in bytes, groups can
+// override it by RouteGroup.MaxBodySize
+func WithMaxBodySize(size int64) Option {
+	return func(cc *Cupcake) {
+		cc.RouteGroup.MaxBodySize(size)
+	}
+}
+
+// WithMaxDecompressedSize sets max size of gzip request body after it is
+// decompressed, 0 means no limit
+func WithMaxDecompressedSize(size int64) Option {
+	return func(cc *Cupcake) {
+		cc.maxDecompressedSize = size
+	}
+}
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strings"
@@ -14,6 +15,9 @@ type DB interface {
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
 	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
 var _ DB = (*sql.DB)(nil)
@@ -26,6 +30,8 @@ type Session struct {
 	trans     translator.Translator
 	schema    *schema.Schema
 	statement *statement
+	// Queries are aborted when ctx is done
+	ctx context.Context
 }
 
 func New(db *sql.DB, trans translator.Translator) *Session {
@@ -36,6 +42,25 @@ func New(db *sql.DB, trans translator.Translator) *Session {
 	}
 }
 
+// WithContext returns a new session with the same db and model, queries
+// of the new session are aborted when ctx is done
+func (s *Session) WithContext(ctx context.Context) *Session {
+	return &Session{
+		db:        s.db,
+		trans:     s.trans,
+		schema:    s.schema,
+		statement: &statement{},
+		ctx:       ctx,
+	}
+}
+
+func (s Session) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
 func (s *Session) Clear() {
 	s.sql.Reset()
 	s.sqlVars = nil
@@ -57,7 +82,7 @@ func (s *Session) Exec() (result sql.Result, err error) {
 	// Reset session after successfully execute previous query
 	defer s.Clear()
 	log.Info(s.sql.String(), s.sqlVars)
-	if result, err = s.DB().Exec(s.sql.String(), s.sqlVars...); err != nil {
+	if result, err = s.DB().ExecContext(s.Context(), s.sql.String(), s.sqlVars...); err != nil {
 		log.Error(err)
 	}
 	return
@@ -67,13 +92,13 @@ func (s *Session) QueryRow() *sql.Row {
 	// Reset session after successfully execute previous query
 	defer s.Clear()
 	log.Info(s.sql.String(), s.sqlVars)
-	return s.DB().QueryRow(s.sql.String(), s.sqlVars...)
+	return s.DB().QueryRowContext(s.Context(), s.sql.String(), s.sqlVars...)
 }
 func (s *Session) QueryRows() (rows *sql.Rows, err error) {
 	// Reset session after successfully execute previous query
 	defer s.Clear()
 	log.Info(s.sql.String(), s.sqlVars)
-	if rows, err = s.DB().Query(s.sql.String(), s.sqlVars...); err != nil {
+	if rows, err = s.DB().QueryContext(s.Context(), s.sql.String(), s.sqlVars...); err != nil {
 		log.Error(err)
 	}
 	return
@@ -87,7 +112,7 @@ func (s *Session) Begin() (err error) {
 		log.Error(err)
 		return
 	}
-	s.db, err = db.Begin()
+	s.db, err = db.BeginTx(s.Context(), nil)
 	if err != nil {
 		log.Error(err)
 		return err
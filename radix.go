@@ -6,11 +6,15 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 type nodeType uint8
 type methodType uint8
 
+// Children of a node are matched in the order of their node type, so the priority
+// is static > regex > param > wildcard. Param and regex siblings which could
+// match the same segment are rejected as conflicts when registering.
 const (
 	StaticNode nodeType = iota
 	RegrexNode
@@ -23,18 +27,51 @@ const (
 	POST
 	PUT
 	DELETE
+	PATCH
+	HEAD
+	OPTIONS
+	CONNECT
+	TRACE
 )
 
-var methodMapping = map[string]methodType{
-	http.MethodGet:    GET,
-	http.MethodPost:   POST,
-	http.MethodPut:    PUT,
-	http.MethodDelete: DELETE,
-}
+// Standard methods, custom methods registered by Handle are appended after them
+// anyMethod is the method of endpoints handling requests of every method,
+// including custom methods which are not registered
+const anyMethod = ^methodType(0)
+
+var standardMethods = []methodType{GET, POST, PUT, DELETE, PATCH, HEAD, OPTIONS, CONNECT, TRACE}
+
+var (
+	methodMu      sync.RWMutex
+	methodMapping = map[string]methodType{
+		http.MethodGet:     GET,
+		http.MethodPost:    POST,
+		http.MethodPut:     PUT,
+		http.MethodDelete:  DELETE,
+		http.MethodPatch:   PATCH,
+		http.MethodHead:    HEAD,
+		http.MethodOptions: OPTIONS,
+		http.MethodConnect: CONNECT,
+		http.MethodTrace:   TRACE,
+	}
+	methodNames = []string{
+		http.MethodGet,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodDelete,
+		http.MethodPatch,
+		http.MethodHead,
+		http.MethodOptions,
+		http.MethodConnect,
+		http.MethodTrace,
+	}
+)
 
 var (
-	ErrNotAllow = errors.New("Method Not Allowed")
-	ErrNotFound = errors.New("NOT FOUND")
+	ErrNotAllow       = errors.New("Method Not Allowed")
+	ErrNotFound       = errors.New("NOT FOUND")
+	ErrNotImplemented = errors.New("Not Implemented")
+	ErrRouteConflict  = errors.New("route conflict")
 )
 
 type radixNode struct {
@@ -42,6 +79,7 @@ type radixNode struct {
 	tail      byte
 	nodeType  nodeType
 	prefix    string
+	regex     string
 	rex       *regexp.Regexp
 	children  []radixNodes
 	endpoints map[methodType]*endpoint
@@ -65,22 +103,34 @@ func NewNode(prefix string) *radixNode {
 	return node
 }
 
-func (node *radixNode) InsertNode(path string, method methodType, handler HandlerFunc) {
+func (node *radixNode) InsertNode(path string, method methodType, handler HandlerFunc) error {
 	curNode := node
 	search := path
 	paramKeys := []string{}
 	for {
 		if len(search) == 0 {
-			curNode.setEndpoint(paramKeys, method, handler)
-			return
+			return curNode.setEndpoint(path, paramKeys, method, handler)
 		}
-		pType, pattern, _, _, _, _, _ := parsePath(search)
-		next := curNode.findNext(pType, search)
+		pType, pattern, regex, tail, _, _, nextStart := parsePath(search)
 
 		if (pType == ParamNode || pType == RegrexNode) && pattern != "" {
 			paramKeys = append(paramKeys, pattern)
 		}
 
+		if pType != StaticNode {
+			// Reuse the same segment registered before
+			next, err := curNode.findSegment(pType, pattern, regex, tail)
+			if err != nil {
+				return fmt.Errorf("%w: %s %s, %s", ErrRouteConflict, method, path, err.Error())
+			}
+			if next != nil {
+				search = search[nextStart:]
+				curNode = next
+				continue
+			}
+		}
+		next := curNode.findNext(pType, search)
+
 		// next node not found
 		if next == nil {
 			child, nextStart := curNode.addNode(search)
@@ -127,7 +177,7 @@ func (node *radixNode) Route(path string, method methodType) (handler HandlerFun
 		return
 	}
 
-	endpoint := child.endpoints[method]
+	endpoint := child.endpoint(method)
 	if len(endpoint.paramKeys) != len(paramVals) {
 		panic("ParamKeys and ParamVals do not match")
 	}
@@ -187,12 +237,15 @@ func (node *radixNode) route(path string, method methodType) (child *radixNode,
 				tempSearch = tempSearch[tailIdx:]
 				if len(tempSearch) == 0 {
 					// Find endpoints
-					if tempCurrent.endpoints != nil && tempCurrent.endpoints[method] != nil {
+					if tempCurrent.endpoint(method) != nil {
 						child = tempCurrent
 						err = nil
 						return
 					}
-					err = ErrNotAllow
+					// Nodes without endpoints are only part of longer paths
+					if len(tempCurrent.endpoints) > 0 {
+						err = ErrNotAllow
+					}
 					continue
 				}
 
@@ -212,6 +265,8 @@ func (node *radixNode) route(path string, method methodType) (child *radixNode,
 				paramVals = paramVals[:valsSize]
 				tempSearch = search
 			}
+			// No param node matches
+			continue
 		default:
 			if len(nodeGroup) > 0 {
 				tempCurrent = nodeGroup[0]
@@ -225,12 +280,14 @@ func (node *radixNode) route(path string, method methodType) (child *radixNode,
 		}
 		//Found node
 		if len(tempSearch) == 0 {
-			if tempCurrent.endpoints != nil && tempCurrent.endpoints[method] != nil {
+			if tempCurrent.endpoint(method) != nil {
 				child = tempCurrent
 				err = nil
 				return
 			}
-			err = ErrNotAllow
+			if len(tempCurrent.endpoints) > 0 {
+				err = ErrNotAllow
+			}
 			continue
 		}
 
@@ -243,8 +300,9 @@ func (node *radixNode) route(path string, method methodType) (child *radixNode,
 			err = nil
 			return
 		}
-		// Fail to find node in this group
-		if err != ErrNotFound {
+		// Fail to find node in this group, ErrNotAllow found in other
+		// groups is kept
+		if err != ErrNotAllow {
 			err = newErr
 		}
 	}
@@ -255,6 +313,63 @@ func (node *radixNode) route(path string, method methodType) (child *radixNode,
 	return
 }
 
+// findCaseInsensitive matches path case-insensitively with static segments
+// and returns the path in its registered case
+func (node *radixNode) findCaseInsensitive(path string) (string, bool) {
+	for t, nodeGroup := range node.children {
+		switch nodeType(t) {
+		case StaticNode:
+			for _, next := range nodeGroup {
+				if len(path) < len(next.prefix) || !strings.EqualFold(path[:len(next.prefix)], next.prefix) {
+					continue
+				}
+				rest := path[len(next.prefix):]
+				if rest == "" {
+					if len(next.endpoints) > 0 {
+						return next.prefix, true
+					}
+					continue
+				}
+				if res, ok := next.findCaseInsensitive(rest); ok {
+					return next.prefix + res, true
+				}
+			}
+		case ParamNode, RegrexNode:
+			for _, next := range nodeGroup {
+				tailIdx := strings.IndexByte(path, next.tail)
+				if tailIdx < 0 {
+					if next.tail != '/' {
+						continue
+					}
+					tailIdx = len(path)
+				}
+				segment := path[:tailIdx]
+				if segment == "" || strings.IndexByte(segment, '/') != -1 {
+					continue
+				}
+				if next.rex != nil && !next.rex.MatchString(segment) {
+					continue
+				}
+				rest := path[tailIdx:]
+				if rest == "" {
+					if len(next.endpoints) > 0 {
+						return segment, true
+					}
+					continue
+				}
+				if res, ok := next.findCaseInsensitive(rest); ok {
+					return segment + res, true
+				}
+			}
+		default:
+			if len(nodeGroup) > 0 && len(nodeGroup[0].endpoints) > 0 {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
+
 func (node *radixNode) addNode(path string) (*radixNode, int) {
 	pType, pattern, regex, tail, _, _, nextStart := parsePath(path)
 	child := NewNode(pattern)
@@ -265,10 +380,11 @@ func (node *radixNode) addNode(path string) (*radixNode, int) {
 	}
 
 	if regex != "" {
-		rex, err := regexp.Compile(regex)
+		rex, err := regexp.Compile(resolveConstraint(regex))
 		if err != nil {
 			panic(fmt.Sprintf("Invalid regexp pattern '%s' in path", regex))
 		}
+		child.regex = regex
 		child.rex = rex
 	}
 
@@ -383,15 +499,68 @@ func (node *radixNode) findNext(pType nodeType, pattern string) *radixNode {
 	return nil
 }
 
-func (node *radixNode) setEndpoint(paramKeys []string, method methodType, handler HandlerFunc) {
+// findSegment finds child with the same param, regex or wildcard segment,
+// returns error if the segment is ambiguous with an existing one. A param is
+// ambiguous with any other param or regex sibling, and a regex is ambiguous
+// with a param sibling or a regex sibling followed by the same byte.
+func (node *radixNode) findSegment(pType nodeType, key string, regex string, tail byte) (*radixNode, error) {
+	switch pType {
+	case WildNode:
+		if len(node.children[WildNode]) > 0 {
+			return node.children[WildNode][0], nil
+		}
+	case ParamNode:
+		if len(node.children[RegrexNode]) > 0 {
+			next := node.children[RegrexNode][0]
+			return nil, fmt.Errorf("param {%s} is ambiguous with {%s:%s}", key, next.prefix, next.regex)
+		}
+		if len(node.children[ParamNode]) > 0 {
+			next := node.children[ParamNode][0]
+			if next.prefix != key {
+				return nil, fmt.Errorf("param {%s} is ambiguous with {%s}", key, next.prefix)
+			}
+			return next, nil
+		}
+	case RegrexNode:
+		if len(node.children[ParamNode]) > 0 {
+			next := node.children[ParamNode][0]
+			return nil, fmt.Errorf("param {%s:%s} is ambiguous with {%s}", key, regex, next.prefix)
+		}
+		for _, next := range node.children[RegrexNode] {
+			if next.tail != tail {
+				continue
+			}
+			if next.prefix != key || resolveConstraint(next.regex) != resolveConstraint(regex) {
+				return nil, fmt.Errorf("param {%s:%s} is ambiguous with {%s:%s}", key, regex, next.prefix, next.regex)
+			}
+			return next, nil
+		}
+	}
+	return nil, nil
+}
+
+// endpoint returns endpoint of the node for given method, or the one for any
+// method if there is none
+func (node *radixNode) endpoint(method methodType) *endpoint {
+	if endpoint, ok := node.endpoints[method]; ok {
+		return endpoint
+	}
+	return node.endpoints[anyMethod]
+}
+
+func (node *radixNode) setEndpoint(path string, paramKeys []string, method methodType, handler HandlerFunc) error {
 	if node.endpoints == nil {
 		node.endpoints = make(map[methodType]*endpoint)
 	}
+	if _, ok := node.endpoints[method]; ok {
+		return fmt.Errorf("%w: %s %s is already registered", ErrRouteConflict, method, path)
+	}
 
 	node.endpoints[method] = &endpoint{
 		paramKeys: paramKeys,
 		handler:   handler,
 	}
+	return nil
 }
 
 func longestCommonPrefix(origin string, target string) int {
@@ -407,9 +576,43 @@ func longestCommonPrefix(origin string, target string) int {
 	return length
 }
 
-func parseMethod(method string) methodType {
+// parseMethod returns the methodType of given method, ok is false if the method
+// has never been registered
+func parseMethod(method string) (methodType, bool) {
+	methodMu.RLock()
+	defer methodMu.RUnlock()
+	m, ok := methodMapping[method]
+	return m, ok
+}
+
+// registerMethod returns the methodType of given method, a new methodType
+// will be allocated for unknown custom method
+func registerMethod(method string) methodType {
+	if method == "" || strings.ContainsAny(method, " \t\r\n") {
+		panic(fmt.Sprintf("Invalid method: '%s'", method))
+	}
+	methodMu.Lock()
+	defer methodMu.Unlock()
 	if m, ok := methodMapping[method]; ok {
 		return m
 	}
-	panic(fmt.Sprintf("Invalid mathod: %s", method))
+	if len(methodNames) >= int(anyMethod) {
+		panic("Too many custom methods")
+	}
+	m := methodType(len(methodNames))
+	methodMapping[method] = m
+	methodNames = append(methodNames, method)
+	return m
+}
+
+func (m methodType) String() string {
+	methodMu.RLock()
+	defer methodMu.RUnlock()
+	if int(m) < len(methodNames) {
+		return methodNames[m]
+	}
+	if m == anyMethod {
+		return "ANY"
+	}
+	return fmt.Sprintf("methodType(%d)", m)
 }
@@ -1,24 +1,18 @@
 package cupcake
 
 import (
-	"bytes"
-	"compress/gzip"
+	"context"
 	"encoding/json"
-	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
-	"net/url"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/lz-nsc/cupcake/log"
-	"google.golang.org/protobuf/proto"
-	"gopkg.in/yaml.v3"
 )
 
 type Request struct {
@@ -26,18 +20,41 @@ type Request struct {
 	path   string
 	method string
 	params map[string]string
-	data   []byte
 	wild   string
+	// Error occurred when routing the request
+	routeErr error
+	// Request-scoped values shared by middlewares and handlers
+	store *valueStore
+	// Body with size limits, nil if no limit is applied
+	body *bodyReader
+	// Path prefix stripped by mounting engines
+	mountPrefix string
+	// Max size of multipart form kept in memory
+	multipartMemory int64
+	fileLimits      FileLimits
+}
+
+type valueStore struct {
+	mu     sync.RWMutex
+	values map[string]interface{}
 }
 
 const (
-	ApplicationJSON  = "application/json"
-	ApplicationXML   = "application/xml"
-	ApplicationForm  = "application/x-www-form-urlencoded"
-	MultipartForm    = "multipart/form-data"
-	ApplicationProto = "application/x-protobuf"
-	ApplicationYAML  = "application/x-yaml"
-	TextXML          = "text/xml"
+	ApplicationJSON   = "application/json"
+	ApplicationXML    = "application/xml"
+	ApplicationForm   = "application/x-www-form-urlencoded"
+	MultipartForm     = "multipart/form-data"
+	ApplicationProto  = "application/x-protobuf"
+	ApplicationYAML   = "application/x-yaml"
+	TextXML           = "text/xml"
+	ApplicationNDJSON = "application/x-ndjson"
+	TextCSV           = "text/csv"
+)
+
+var (
+	ErrMissingParam = errors.New("missing param")
+
+	uuidRex = regexp.MustCompile(resolveConstraint("uuid"))
 )
 
 func NewRequest(r *http.Request) *Request {
@@ -46,11 +63,86 @@ func NewRequest(r *http.Request) *Request {
 		path:   r.URL.Path,
 		method: r.Method,
 		params: make(map[string]string),
-		data:   []byte{},
+		store:  &valueStore{values: map[string]interface{}{}},
+
+		multipartMemory: defaultMultipartMemory,
 	}
 
 	return req
 }
+
+// Context returns context of the request, which is canceled when
+// the client disconnects
+func (r *Request) Context() context.Context {
+	return r.req.Context()
+}
+
+// ShuttingDown returns a channel closed when the server starts shutting
+// down, so long-lived requests can finish early. The channel is nil if the
+// request is not served by Server
+func (r *Request) ShuttingDown() <-chan struct{} {
+	return shuttingDown(r.req)
+}
+
+func shuttingDown(req *http.Request) <-chan struct{} {
+	stopping, _ := req.Context().Value(stoppingKey{}).(chan struct{})
+	return stopping
+}
+
+// WithContext returns a shallow copy of the request with its context
+// changed to ctx, values set by Set are shared with the copy
+func (r *Request) WithContext(ctx context.Context) *Request {
+	req := new(Request)
+	*req = *r
+	req.req = r.req.WithContext(ctx)
+	return req
+}
+
+// Set stores a request-scoped value with given key
+func (r *Request) Set(key string, val interface{}) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.values[key] = val
+}
+
+// Get returns value stored with given key, ok is false if there is no such value
+func (r *Request) Get(key string) (val interface{}, ok bool) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	val, ok = r.store.values[key]
+	return
+}
+
+// MustGet returns value stored with given key, panics if there is no such value
+func (r *Request) MustGet(key string) interface{} {
+	val, ok := r.Get(key)
+	if !ok {
+		panic(fmt.Sprintf("Key '%s' does not exist in request", key))
+	}
+	return val
+}
+
+// GetString returns value stored with given key if it is a string
+func (r *Request) GetString(key string) (string, bool) {
+	val, _ := r.Get(key)
+	str, ok := val.(string)
+	return str, ok
+}
+
+// GetInt returns value stored with given key if it is an int
+func (r *Request) GetInt(key string) (int, bool) {
+	val, _ := r.Get(key)
+	num, ok := val.(int)
+	return num, ok
+}
+
+// GetBool returns value stored with given key if it is a bool
+func (r *Request) GetBool(key string) (bool, bool) {
+	val, _ := r.Get(key)
+	b, ok := val.(bool)
+	return b, ok
+}
+
 func (r *Request) PostForm(key string) string {
 	return r.req.FormValue(key)
 }
@@ -59,6 +151,11 @@ func (r *Request) Query(key string) string {
 	return r.req.URL.Query().Get(key)
 }
 
+// Header returns the first value of request header with given key
+func (r *Request) Header(key string) string {
+	return r.req.Header.Get(key)
+}
+
 func (r Request) String() string {
 	return fmt.Sprintf("Request: %s %s", r.method, r.path)
 }
@@ -67,6 +164,10 @@ func (r Request) Path() string {
 	return r.path
 }
 
+func (r Request) Host() string {
+	return r.req.Host
+}
+
 func (r Request) Method() string {
 	return r.method
 }
@@ -84,167 +185,181 @@ func (r Request) Param(key string) string {
 	return r.params[key]
 }
 
+// ParamInt returns param with given key as int
+func (r Request) ParamInt(key string) (int, error) {
+	val, err := r.ParamInt64(key)
+	return int(val), err
+}
+
+// ParamInt64 returns param with given key as int64
+func (r Request) ParamInt64(key string) (int64, error) {
+	str, err := r.requireParam(key)
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return 0, paramError(key, "int", err)
+	}
+	return val, nil
+}
+
+// ParamUint returns param with given key as uint64
+func (r Request) ParamUint(key string) (uint64, error) {
+	str, err := r.requireParam(key)
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return 0, paramError(key, "uint", err)
+	}
+	return val, nil
+}
+
+// ParamFloat returns param with given key as float64
+func (r Request) ParamFloat(key string) (float64, error) {
+	str, err := r.requireParam(key)
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, paramError(key, "float", err)
+	}
+	return val, nil
+}
+
+// ParamBool returns param with given key as bool
+func (r Request) ParamBool(key string) (bool, error) {
+	str, err := r.requireParam(key)
+	if err != nil {
+		return false, err
+	}
+	val, err := strconv.ParseBool(str)
+	if err != nil {
+		return false, paramError(key, "bool", err)
+	}
+	return val, nil
+}
+
+// ParamUUID returns param with given key after checking it is a valid uuid
+func (r Request) ParamUUID(key string) (string, error) {
+	str, err := r.requireParam(key)
+	if err != nil {
+		return "", err
+	}
+	if !uuidRex.MatchString(str) {
+		return "", paramError(key, "uuid", errors.New("invalid format"))
+	}
+	return str, nil
+}
+
+// ParamDate returns param with given key as date in format 2006-01-02
+func (r Request) ParamDate(key string) (time.Time, error) {
+	str, err := r.requireParam(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	val, err := time.ParseInLocation("2006-01-02", str, time.Local)
+	if err != nil {
+		return time.Time{}, paramError(key, "date", err)
+	}
+	return val, nil
+}
+
+func (r Request) requireParam(key string) (string, error) {
+	str, ok := r.params[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrMissingParam, key)
+	}
+	return str, nil
+}
+
+func paramError(key string, typ string, err error) error {
+	return fmt.Errorf("param '%s' is not a valid %s: %w", key, typ, err)
+}
+
+// RouteError returns the error occurred when routing the request,
+// such as ErrNotFound and ErrNotAllow
+func (r Request) RouteError() error {
+	return r.routeErr
+}
+
 func (r Request) Wild() string {
 	return r.wild
 }
 
+// Body returns body of the request, which is read lazily so handlers can
+// stream it. Gzip body is decompressed, and ErrBodyTooLarge is returned
+// when reading beyond size limits
 func (r Request) Body() io.ReadCloser {
 	return r.req.Body
 }
 
 func (r Request) Parse(obj interface{}) error {
 	ct := r.req.Header.Get("Content-Type")
-	if len(ct) == 0 {
-		return r.parseJson(obj)
-	}
 	vals := strings.Split(ct, ";")
 	switch vals[0] {
 	case ApplicationForm:
 		return r.parseForm(obj)
-	case ApplicationProto:
-		return r.parseProto(obj)
-	case ApplicationYAML:
-		return r.parseYaml(obj)
-	case ApplicationXML, TextXML:
-		return r.parseXml(obj)
-	case ApplicationJSON:
-		return r.parseJson(obj)
-	default:
-		return errors.New("Unsupported Content-Type:" + ct)
+	case MultipartForm:
+		return r.parseMultipart(obj)
 	}
-}
 
-func (r *Request) readData() {
-	if r.req.Body == nil {
-		return
+	codec, ok := getCodec(vals[0])
+	if vals[0] == "" {
+		codec, ok = jsonCodec{}, true
 	}
-	var data []byte
-	// Decode gzip request
-	if ce := r.req.Header.Values("Content-Encoding"); len(ce) != 0 {
-		vals := strings.Split(ce[0], ",")
-		for _, val := range vals {
-			if val == "gzip" {
-				gReader, err := gzip.NewReader(r.Body())
-				if err != nil {
-					return
-				}
-				data, _ = ioutil.ReadAll(gReader)
-			}
-		}
+	if !ok {
+		return errors.New("Unsupported Content-Type:" + ct)
 	}
-	if data == nil {
-		data, _ = ioutil.ReadAll(r.req.Body)
+	if r.req.Body == nil {
+		return codec.Decode(http.NoBody, obj)
 	}
-	buffer := bytes.NewBuffer(data)
-	r.req.Body = ioutil.NopCloser(buffer)
-	r.data = data
+	// Decode while reading so that the body is not held in memory
+	if err := codec.Decode(r.req.Body, obj); err != nil {
+		return r.bodyError(err)
+	}
+	return nil
 }
 
-func (r Request) parseJson(obj interface{}) error {
-	return json.Unmarshal(r.data, obj)
+// Decoder returns json.Decoder reading body of the request, which decodes
+// NDJSON or a stream of JSON values one by one while the body is received:
+//
+//	decoder := req.Decoder()
+//	for {
+//		var event Event
+//		if err := decoder.Decode(&event); err == io.EOF {
+//			break
+//		} else if err != nil {
+//			resp.Error(http.StatusBadRequest, err.Error())
+//			return
+//		}
+//		handle(event)
+//	}
+func (r Request) Decoder() *json.Decoder {
+	if r.req.Body == nil {
+		return json.NewDecoder(http.NoBody)
+	}
+	return json.NewDecoder(r.req.Body)
 }
+
 func (r Request) parseForm(obj interface{}) error {
 	err := r.req.ParseForm()
 	if err != nil {
-		return err
+		return r.bodyError(err)
 	}
-	objType := reflect.TypeOf(obj)
-	if objType.Kind() != reflect.Ptr && objType.Elem().Kind() != reflect.Struct {
+	objVal := reflect.ValueOf(obj)
+	if objVal.Kind() != reflect.Ptr || objVal.IsNil() || objVal.Elem().Kind() != reflect.Struct {
 		return errors.New("given obj is not pointer to a struct")
 	}
-	objValElm := reflect.ValueOf(obj).Elem()
-	objTypeElm := objType.Elem()
-	parseFormToStruct(r.req.Form, objTypeElm, objValElm)
-	return nil
-}
-
-func parseFormToStruct(form url.Values, objType reflect.Type, objVal reflect.Value) {
-	for idx := 0; idx < objType.NumField(); idx++ {
-		field := objVal.Field(idx)
-		if !field.CanSet() {
-			continue
-		}
-		fieldType := objType.Field(idx)
-		if fieldType.Anonymous && fieldType.Type.Kind() == reflect.Struct {
-			parseFormToStruct(form, fieldType.Type, field)
-			continue
-		}
-		fieldName, ok := getformName(fieldType)
-		if !ok {
-			continue
-		}
-
-		fieldVal := form[fieldName]
-		if len(fieldVal) == 0 || fieldVal[0] == "" {
-			continue
-		}
-		var err error
-		// Convert string to the exact type of the field
-		switch fieldType.Type.Kind() {
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			val, err := strconv.ParseInt(fieldVal[0], 10, 64)
-			if err == nil {
-				field.SetInt(val)
-			}
-		case reflect.Bool:
-			val, err := strconv.ParseBool(fieldVal[0])
-			if err == nil {
-				field.SetBool(val)
-			}
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			val, err := strconv.ParseUint(fieldVal[0], 10, 64)
-			if err == nil {
-				field.SetUint(val)
-			}
-		case reflect.Float32, reflect.Float64:
-			val, err := strconv.ParseFloat(fieldVal[0], 64)
-			if err == nil {
-				field.SetFloat(val)
-			}
-		case reflect.Interface:
-			field.Set(reflect.ValueOf(fieldVal[0]))
-		case reflect.String:
-			field.SetString(fieldVal[0])
-		case reflect.Struct:
-			if fieldType.Type.String() == "time.Time" {
-				t, err := parseFormTime(fieldVal[0])
-				if err == nil {
-					field.Set(reflect.ValueOf(t))
-				}
-			}
-		case reflect.Slice:
-			if fieldType.Type == reflect.TypeOf([]int(nil)) {
-				formVals := form[fieldName]
-				field.Set(reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(int(1))), len(formVals), len(formVals)))
-				for i := 0; i < len(formVals); i++ {
-					val, err := strconv.Atoi(formVals[i])
-					if err == nil {
-						field.Index(i).SetInt(int64(val))
-					}
-				}
-			} else if fieldType.Type == reflect.TypeOf([]string(nil)) {
-				formVals := form[fieldName]
-				field.Set(reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf("")), len(formVals), len(formVals)))
-				for i := 0; i < len(formVals); i++ {
-					field.Index(i).SetString(formVals[i])
-				}
-			}
-		}
-		if err != nil {
-			log.Debugf("parse bool error, err: %s", err.Error())
-		}
+	if errs := bindForm(r.req.Form, nil, r.fileLimits, objVal.Elem(), nil); len(errs) > 0 {
+		return errs
 	}
+	return nil
 }
 
-func (r Request) parseProto(obj interface{}) error {
-	return proto.Unmarshal(r.data, obj.(proto.Message))
-}
-func (r Request) parseYaml(obj interface{}) error {
-	return yaml.Unmarshal(r.data, obj)
-}
-func (r Request) parseXml(obj interface{}) error {
-	return xml.Unmarshal(r.data, obj)
-}
 func getformName(field reflect.StructField) (string, bool) {
 	tags := strings.Split(field.Tag.Get("form"), ",")
 	var tag string
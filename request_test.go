@@ -0,0 +1,116 @@
+package cupcake
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+func TestRequestStore(t *testing.T) {
+	cc := New()
+	cc.MiddlerWare(func(next HandlerFunc) HandlerFunc {
+		return func(resp *Response, req *Request) {
+			req.Set("user", "cupcake")
+			req.Set("id", 7)
+			req.Set("admin", true)
+			next(resp, req)
+			// Values set by the handler are visible to middlewares
+			if val, _ := req.GetString("result"); val != "done" {
+				t.Errorf("got result %q, want %q", val, "done")
+			}
+		}
+	})
+	cc.GET("/me", func(resp *Response, req *Request) {
+		if user, ok := req.GetString("user"); !ok || user != "cupcake" {
+			t.Errorf("got user %q, %v", user, ok)
+		}
+		if id, ok := req.GetInt("id"); !ok || id != 7 {
+			t.Errorf("got id %d, %v", id, ok)
+		}
+		if admin, ok := req.GetBool("admin"); !ok || !admin {
+			t.Errorf("got admin %v, %v", admin, ok)
+		}
+		if _, ok := req.GetInt("user"); ok {
+			t.Error("string value is returned as int")
+		}
+		if _, ok := req.Get("missing"); ok {
+			t.Error("missing value is found")
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("MustGet of missing value does not panic")
+				}
+			}()
+			req.MustGet("missing")
+		}()
+		req.Set("result", "done")
+		resp.Status(http.StatusNoContent)
+	})
+
+	if w := serve(cc, http.MethodGet, "/me"); w.Code != http.StatusNoContent {
+		t.Errorf("got %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestRequestWithContext(t *testing.T) {
+	cc := New()
+	cc.MiddlerWare(func(next HandlerFunc) HandlerFunc {
+		return func(resp *Response, req *Request) {
+			req.Set("user", "cupcake")
+			next(resp, req.WithContext(context.WithValue(req.Context(), ctxKey{}, "traced")))
+		}
+	})
+	cc.GET("/users/{id}", func(resp *Response, req *Request) {
+		val, _ := req.Context().Value(ctxKey{}).(string)
+		user, _ := req.GetString("user")
+		resp.String(http.StatusOK, "%s %s %s", val, user, req.Param("id"))
+	})
+
+	if w := serve(cc, http.MethodGet, "/users/5"); w.Body.String() != "traced cupcake 5" {
+		t.Errorf("got %q", w.Body.String())
+	}
+}
+
+func TestRequestContextCanceled(t *testing.T) {
+	started := make(chan struct{})
+	errs := make(chan error, 1)
+	cc := New()
+	cc.GET("/slow", func(resp *Response, req *Request) {
+		close(started)
+		select {
+		case <-req.Context().Done():
+			errs <- req.Context().Err()
+		case <-time.After(2 * time.Second):
+			errs <- nil
+		}
+	})
+	srv, addr, errCh := startServer(t, cc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/slow", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		if res, err := http.DefaultClient.Do(r); err == nil {
+			res.Body.Close()
+		}
+	}()
+	<-started
+	// Client disconnecting cancels context of the request
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,16 +1,34 @@
 package cupcake
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lz-nsc/cupcake/log"
 )
 
 type Response struct {
 	writer     http.ResponseWriter
 	statusCode int
 	render     *template.Template
+	// Request being responded, used to negotiate content
+	req *http.Request
+
+	// Whether status or body has been written, and size of the body
+	written bool
+	size    int
+	// Whether header has been written to writer
+	wroteHeader bool
+	// Body kept until the response is flushed, nil if it is not buffered
+	buffer *bytes.Buffer
+	// Event stream started by SSE, closed when the route handler returns
+	stream *EventStream
 }
 
 func NewResponse(w http.ResponseWriter, render *template.Template) *Response {
@@ -20,12 +38,84 @@ func NewResponse(w http.ResponseWriter, render *template.Template) *Response {
 	}
 }
 
+// Buffer keeps status and body of the response until the handler returns,
+// so middlewares can inspect and rewrite them. It must be called before
+// anything is written
+func (resp *Response) Buffer() *Response {
+	if resp.buffer == nil && !resp.wroteHeader {
+		resp.buffer = &bytes.Buffer{}
+	}
+	return resp
+}
+
+// Buffered reports whether the response is buffered
+func (resp *Response) Buffered() bool {
+	return resp.buffer != nil
+}
+
+// Written reports whether status or body has been written
+func (resp *Response) Written() bool {
+	return resp.written
+}
+
+// Size returns size of body written
+func (resp *Response) Size() int {
+	return resp.size
+}
+
+// Body returns buffered body, nil if the response is not buffered
+func (resp *Response) Body() []byte {
+	if resp.buffer == nil {
+		return nil
+	}
+	return resp.buffer.Bytes()
+}
+
+// SetBody replaces buffered body, it does nothing if the response is not
+// buffered. Content-Length set before is removed and filled when flushing
+func (resp *Response) SetBody(body []byte) *Response {
+	if resp.buffer == nil {
+		return resp
+	}
+	resp.writer.Header().Del("Content-Length")
+	resp.buffer.Reset()
+	resp.buffer.Write(body)
+	resp.size = len(body)
+	return resp
+}
+
+// Header returns header of the response, which can be changed until the
+// response is flushed in buffered mode
+func (resp *Response) Header() http.Header {
+	return resp.writer.Header()
+}
+
+// Status sets status code of the response, it is written immediately unless
+// the response is buffered, and status set after the header is written is
+// ignored
 func (resp *Response) Status(code int) *Response {
+	if resp.wroteHeader {
+		return resp
+	}
 	resp.statusCode = code
-	resp.writer.WriteHeader(code)
+	resp.written = true
+	if resp.buffer == nil {
+		resp.writeHeader()
+	}
 	return resp
 }
 
+func (resp *Response) writeHeader() {
+	if resp.wroteHeader {
+		return
+	}
+	if resp.statusCode == 0 {
+		resp.statusCode = http.StatusOK
+	}
+	resp.wroteHeader = true
+	resp.writer.WriteHeader(resp.statusCode)
+}
+
 func (resp *Response) SetHeader(key string, value string) *Response {
 	resp.writer.Header().Set(key, value)
 	return resp
@@ -38,6 +128,21 @@ func (resp *Response) SetHeaders(headers map[string]string) *Response {
 	return resp
 }
 
+// AddVary adds field to Vary header if it is not there yet, so that
+// middlewares and content negotiation can all add to it without duplicates
+func (resp *Response) AddVary(field string) *Response {
+	header := resp.Header()
+	for _, vary := range header.Values("Vary") {
+		for _, existing := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), field) {
+				return resp
+			}
+		}
+	}
+	header.Add("Vary", field)
+	return resp
+}
+
 func (resp *Response) String(code int, format string, values ...interface{}) {
 	resp.SetHeader(
 		"Content-Type", "text/plain",
@@ -47,11 +152,11 @@ func (resp *Response) String(code int, format string, values ...interface{}) {
 }
 
 func (resp *Response) JSON(code int, obj interface{}) {
-	resp.SetHeader("Content-Type", "application/json").Status(code)
-	encoder := json.NewEncoder(resp.writer)
-	if err := encoder.Encode(obj); err != nil {
+	var buffer bytes.Buffer
+	if err := json.NewEncoder(&buffer).Encode(obj); err != nil {
 		panic(err)
 	}
+	resp.SetHeader("Content-Type", "application/json").Status(code).write(buffer.Bytes())
 }
 
 func (resp *Response) Data(code int, data []byte) {
@@ -68,26 +173,153 @@ func (resp *Response) HTML(code int, html string) {
 	)
 }
 
+// Render executes the template before anything is written, so failure of
+// the template is responded with 500 instead of a partial page
 func (resp *Response) Render(code int, tmplName string, data interface{}) {
+	var buffer bytes.Buffer
+	if err := resp.render.ExecuteTemplate(&buffer, tmplName, data); err != nil {
+		resp.Error(http.StatusInternalServerError, err.Error())
+		return
+	}
 	resp.SetHeader(
 		"Content-Type", "text/html",
 	).Status(
 		code,
-	)
+	).write(buffer.Bytes())
+}
 
-	if err := resp.render.ExecuteTemplate(resp.writer, tmplName, data); err != nil {
-		resp.Error(http.StatusInternalServerError, err.Error())
-	}
+func (resp *Response) Redirect(code int, location string) {
+	resp.SetHeader("Location", location).Status(code)
 }
 
-func (resp *Response) write(content []byte) {
-	resp.writer.Write(content)
+func (resp *Response) write(content []byte) (int, error) {
+	resp.written = true
+	if resp.statusCode == 0 {
+		resp.statusCode = http.StatusOK
+	}
+	if resp.buffer != nil {
+		n, err := resp.buffer.Write(content)
+		resp.size += n
+		return n, err
+	}
+	resp.writeHeader()
+	n, err := resp.writer.Write(content)
+	resp.size += n
+	return n, err
 }
 
+// Error responds plain text error message, buffered body is discarded
 func (resp *Response) Error(errCode int, errMsg string) {
-	http.Error(resp.writer, errMsg, errCode)
+	if resp.buffer != nil {
+		resp.buffer.Reset()
+		resp.size = 0
+	}
+	header := resp.writer.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set("X-Content-Type-Options", "nosniff")
+	resp.Status(errCode).write([]byte(errMsg + "\n"))
+}
+
+// flush writes buffered status and body to writer when the response is
+// finished, Content-Length is set as the body is complete
+func (resp *Response) flush() {
+	if resp.buffer == nil || !resp.written {
+		return
+	}
+	body := resp.buffer.Bytes()
+	if header := resp.writer.Header(); len(body) > 0 && header.Get("Content-Length") == "" {
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	resp.writeHeader()
+	resp.writer.Write(body)
+	resp.buffer = nil
+}
+
+// unbuffer writes buffered status and body without Content-Length and
+// disables buffering, so more body can be streamed
+func (resp *Response) unbuffer() {
+	if resp.buffer == nil {
+		return
+	}
+	body := resp.buffer.Bytes()
+	resp.buffer = nil
+	if !resp.written {
+		return
+	}
+	resp.writeHeader()
+	resp.writer.Write(body)
+}
+
+// Writer returns http.ResponseWriter writing through the response, which
+// can be passed to http.Handler
+func (resp *Response) Writer() http.ResponseWriter {
+	return responseWriter{resp}
+}
+
+// BindError responds error returned by Request.Bind, ValidationErrors are
+// responded with 422 and BindErrors with 400, both in JSON. ErrBodyTooLarge
+// is responded with 413, and ErrInvalidRule with 500 as the struct is wrong
+func (resp *Response) BindError(err error) {
+	var validationErrs ValidationErrors
+	var bindErrs BindErrors
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		resp.Error(http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrInvalidRule):
+		log.Error(err)
+		resp.Error(http.StatusInternalServerError, "500 Internal Server Error")
+	case errors.As(err, &validationErrs):
+		resp.JSON(http.StatusUnprocessableEntity, map[string]interface{}{"errors": validationErrs})
+	case errors.As(err, &bindErrs):
+		resp.JSON(http.StatusBadRequest, map[string]interface{}{"errors": bindErrs})
+	default:
+		resp.Error(http.StatusBadRequest, err.Error())
+	}
 }
 
 func (resp *Response) StatusCode() int {
 	return resp.statusCode
 }
+
+// SetWriter replaces http.ResponseWriter the response writes to and returns
+// the previous one, so middlewares can wrap it:
+//
+//	w := &countWriter{}
+//	w.ResponseWriter = resp.SetWriter(w)
+//	defer resp.SetWriter(w.ResponseWriter)
+func (resp *Response) SetWriter(w http.ResponseWriter) http.ResponseWriter {
+	previous := resp.writer
+	resp.writer = w
+	return previous
+}
+
+// responseWriter adapts Response to http.ResponseWriter
+type responseWriter struct {
+	resp *Response
+}
+
+func (w responseWriter) Header() http.Header {
+	return w.resp.Header()
+}
+
+func (w responseWriter) Write(b []byte) (int, error) {
+	return w.resp.write(b)
+}
+
+func (w responseWriter) WriteHeader(code int) {
+	w.resp.Status(code)
+}
+
+func (w responseWriter) Flush() {
+	w.resp.Flush()
+}
+
+// headWriter discards response body for HEAD requests
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
@@ -0,0 +1,32 @@
+package cupcake
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetBodyContentLength(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cc := New()
+	cc.BufferResponses = true
+	cc.MiddlerWare(func(handler HandlerFunc) HandlerFunc {
+		return func(resp *Response, req *Request) {
+			handler(resp, req)
+			resp.SetBody(bytes.Repeat(resp.Body(), 2))
+		}
+	})
+	cc.Static("/static", dir)
+
+	w := httptest.NewRecorder()
+	cc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/a.txt", nil))
+	if w.Body.String() != "hellohello" || w.Header().Get("Content-Length") != "10" {
+		t.Errorf("got %q with Content-Length %s", w.Body.String(), w.Header().Get("Content-Length"))
+	}
+}
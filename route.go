@@ -0,0 +1,208 @@
+package cupcake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Route is a handler registered with method and pattern
+type Route struct {
+	method  methodType
+	host    string
+	pattern string
+	name    string
+	handler HandlerFunc
+	group   *RouteGroup
+	// Middlewares applied to this route only, inside middlewares of groups
+	middlewares []MiddlerWare
+}
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Method      string   `json:"method"`
+	Host        string   `json:"host,omitempty"`
+	Pattern     string   `json:"pattern"`
+	Name        string   `json:"name,omitempty"`
+	Handler     string   `json:"handler"`
+	Middlewares []string `json:"middlewares"`
+}
+
+var ErrRouteNotFound = errors.New("route not found")
+
+// serve runs handler of the route with middlewares resolved at dispatch
+// time, so middlewares added after the route is registered also apply
+func (route *Route) serve(resp *Response, req *Request) {
+	if !req.limitBody(resp, route.group.bodyLimit(), route.group.engine.maxDecompressedSize) {
+		return
+	}
+	route.chain().Then(route.endpoint)(resp, req)
+}
+
+// endpoint runs handler of the route, event stream started by the handler
+// is closed when it returns so that outer middlewares see a finished response
+func (route *Route) endpoint(resp *Response, req *Request) {
+	defer resp.closeStream()
+	route.handler(resp, req)
+}
+
+// chain returns all middlewares applied to the route
+func (route *Route) chain() Chain {
+	return route.group.middlewareChain().Append(route.middlewares...)
+}
+
+// Info returns description of the route
+func (route *Route) Info() RouteInfo {
+	chain := route.chain()
+	middlewares := make([]string, 0, len(chain))
+	for _, middleware := range chain {
+		middlewares = append(middlewares, funcName(middleware))
+	}
+	return RouteInfo{
+		Method:      route.method.String(),
+		Host:        route.host,
+		Pattern:     route.pattern,
+		Name:        route.name,
+		Handler:     funcName(route.handler),
+		Middlewares: middlewares,
+	}
+}
+
+// Routes returns all registered routes sorted by host, pattern and method
+func (cc *Cupcake) Routes() []RouteInfo {
+	infos := make([]RouteInfo, 0, len(cc.routes))
+	for _, route := range cc.routes {
+		infos = append(infos, route.Info())
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		if infos[i].Host != infos[j].Host {
+			return infos[i].Host < infos[j].Host
+		}
+		if infos[i].Pattern != infos[j].Pattern {
+			return infos[i].Pattern < infos[j].Pattern
+		}
+		mi, _ := parseMethod(infos[i].Method)
+		mj, _ := parseMethod(infos[j].Method)
+		return mi < mj
+	})
+	return infos
+}
+
+// PrintRoutes writes all registered routes to w as a table
+func (cc *Cupcake) PrintRoutes(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tHOST\tPATTERN\tNAME\tHANDLER\tMIDDLEWARES")
+	for _, info := range cc.Routes() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			info.Method, info.Host, info.Pattern, info.Name, info.Handler, strings.Join(info.Middlewares, " -> "))
+	}
+	return tw.Flush()
+}
+
+// RoutesJSON returns all registered routes in JSON format
+func (cc *Cupcake) RoutesJSON() ([]byte, error) {
+	return json.MarshalIndent(cc.Routes(), "", "  ")
+}
+
+func funcName(fn interface{}) string {
+	val := reflect.ValueOf(fn)
+	if val.Kind() != reflect.Func || val.IsNil() {
+		return ""
+	}
+	if f := runtime.FuncForPC(val.Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
+
+// Name names the route so that its url can be generated with URLFor,
+// routes registered with the same pattern can share the name
+func (route *Route) Name(name string) *Route {
+	engine := route.group.engine
+	if named, ok := engine.namedRoutes[name]; ok && named.pattern != route.pattern {
+		panic(fmt.Sprintf("Route name '%s' is already used by '%s'", name, named.pattern))
+	}
+	route.name = name
+	engine.namedRoutes[name] = route
+	return route
+}
+
+// URLFor generates path of the route with given name, params are passed
+// as key value pairs and wildcard can be filled with key "*":
+// cc.URLFor("user", "id", 1)
+func (cc *Cupcake) URLFor(name string, params ...interface{}) (string, error) {
+	route, ok := cc.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrRouteNotFound, name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("params of route '%s' must be key value pairs", name)
+	}
+	values := make(map[string]string, len(params)/2)
+	for idx := 0; idx < len(params); idx += 2 {
+		key, ok := params[idx].(string)
+		if !ok {
+			return "", fmt.Errorf("param key of route '%s' must be string, got %T", name, params[idx])
+		}
+		values[key] = fmt.Sprint(params[idx+1])
+	}
+	return buildPath(route.pattern, values)
+}
+
+// buildPath fills the pattern with values, each value should satisfy
+// the regexp of its segment
+func buildPath(pattern string, values map[string]string) (string, error) {
+	var path strings.Builder
+	used := 0
+	search := pattern
+	for len(search) > 0 {
+		nType, key, regex, _, _, _, nextStart := parsePath(search)
+		search = search[nextStart:]
+		if nType == StaticNode {
+			path.WriteString(key)
+			continue
+		}
+
+		val, ok := values[key]
+		if nType == WildNode {
+			if ok {
+				used++
+				path.WriteString((&url.URL{Path: val}).EscapedPath())
+			}
+			continue
+		}
+		if key == "" {
+			return "", fmt.Errorf("cannot build path '%s' with unnamed segment", pattern)
+		}
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrMissingParam, key)
+		}
+		used++
+		if val == "" || strings.Contains(val, "/") {
+			return "", fmt.Errorf("invalid value '%s' for param '%s'", val, key)
+		}
+		if nType == RegrexNode {
+			// Value must match the whole segment
+			rex, err := regexp.Compile(resolveConstraint(regex))
+			if err != nil {
+				return "", err
+			}
+			if !rex.MatchString(val) {
+				return "", fmt.Errorf("value '%s' of param '%s' does not match '%s'", val, key, regex)
+			}
+		}
+		path.WriteString(url.PathEscape(val))
+	}
+	if used != len(values) {
+		return "", fmt.Errorf("unknown params for path '%s'", pattern)
+	}
+	return path.String(), nil
+}
@@ -0,0 +1,37 @@
+package cupcake
+
+import "testing"
+
+func TestURLForRegexParam(t *testing.T) {
+	cc := New()
+	ok := func(resp *Response, req *Request) {}
+	cc.GET("/items/{id:[0-9]+}", ok).Name("item")
+	cc.GET("/tags/{tag:go|rust}", ok).Name("tag")
+	cc.GET("/users/{uid:uuid}", ok).Name("user")
+
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		path  string
+	}{
+		{"item", "id", "12", "/items/12"},
+		{"item", "id", "12abc", ""},
+		{"tag", "tag", "go", "/tags/go"},
+		{"tag", "tag", "golang", ""},
+		{"user", "uid", "123e4567-e89b-12d3-a456-426614174000", "/users/123e4567-e89b-12d3-a456-426614174000"},
+		{"user", "uid", "x123e4567-e89b-12d3-a456-426614174000", ""},
+	}
+	for _, test := range tests {
+		path, err := cc.URLFor(test.name, test.key, test.value)
+		if test.path == "" {
+			if err == nil {
+				t.Errorf("%s %q: got %q, want error", test.name, test.value, path)
+			}
+			continue
+		}
+		if err != nil || path != test.path {
+			t.Errorf("%s %q: got %q, %v, want %q", test.name, test.value, path, err, test.path)
+		}
+	}
+}
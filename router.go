@@ -1,25 +1,275 @@
 package cupcake
 
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strings"
+)
+
 type router struct {
-	node *radixNode
+	node  *radixNode
+	hosts []*hostRouter
+}
+
+// hostRouter holds routes bound to a host pattern like {tenant}.example.com
+type hostRouter struct {
+	pattern string
+	rex     *regexp.Regexp
+	keys    []string
+	// Indexes of submatches captured for keys
+	groups []int
+	node   *radixNode
 }
 
 func newRouter() *router {
 	return &router{node: NewNode("")}
 }
 
-func (r *router) addRouter(method methodType, path string, handler HandlerFunc) {
-	r.node.InsertNode(path, method, handler)
+func newHostRouter(pattern string) *hostRouter {
+	hr := &hostRouter{
+		pattern: pattern,
+		node:    NewNode(""),
+	}
+	var rex strings.Builder
+	rex.WriteString("(?i)^")
+	rest := pattern
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			rex.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			panic(fmt.Sprintf("Invalid host pattern '%s'", pattern))
+		}
+		end += start
+		rex.WriteString(regexp.QuoteMeta(rest[:start]))
+
+		key, regex := rest[start+1:end], "[^.]+"
+		if idx := strings.IndexByte(key, ':'); idx >= 0 {
+			key, regex = key[:idx], constraintRegex(key[idx+1:])
+		}
+		// Use named group so that groups in regex do not matter
+		rex.WriteString(fmt.Sprintf("(?P<p%d>%s)", len(hr.keys), regex))
+		hr.keys = append(hr.keys, key)
+		rest = rest[end+1:]
+	}
+	rex.WriteString("$")
+
+	compiled, err := regexp.Compile(rex.String())
+	if err != nil {
+		panic(fmt.Sprintf("Invalid host pattern '%s', err: %s", pattern, err))
+	}
+	hr.rex = compiled
+	for idx := range hr.keys {
+		hr.groups = append(hr.groups, compiled.SubexpIndex(fmt.Sprintf("p%d", idx)))
+	}
+	return hr
+}
+
+// match returns params captured from host, ok is false if host does not match
+func (hr *hostRouter) match(host string) (params map[string]string, ok bool) {
+	matches := hr.rex.FindStringSubmatch(host)
+	if matches == nil {
+		return nil, false
+	}
+	params = map[string]string{}
+	for idx, key := range hr.keys {
+		if key != "" {
+			params[key] = matches[hr.groups[idx]]
+		}
+	}
+	return params, true
+}
+
+func (r *router) addRouter(host string, method methodType, path string, handler HandlerFunc) error {
+	return r.hostNode(host).InsertNode(path, method, handler)
+}
+
+// hostNode returns radix tree of given host pattern, a new tree will be
+// created for unknown pattern
+func (r *router) hostNode(host string) *radixNode {
+	if host == "" {
+		return r.node
+	}
+	for _, hr := range r.hosts {
+		if hr.pattern == host {
+			return hr.node
+		}
+	}
+	hr := newHostRouter(host)
+	r.hosts = append(r.hosts, hr)
+	return hr.node
+}
+
+// tree returns radix tree for the host of request and params captured from
+// the host. Hosts without params are matched first, and the default tree
+// is used if no host pattern matches
+func (r *router) tree(host string) (*radixNode, map[string]string) {
+	host = stripPort(host)
+	for _, hr := range r.hosts {
+		if len(hr.keys) == 0 && strings.EqualFold(hr.pattern, host) {
+			return hr.node, nil
+		}
+	}
+	for _, hr := range r.hosts {
+		if len(hr.keys) == 0 {
+			continue
+		}
+		if params, ok := hr.match(host); ok {
+			return hr.node, params
+		}
+	}
+	return r.node, nil
+}
+
+// trees returns radix trees to search for the host in order, paths not found
+// in the tree of the host fall back to the default tree
+func (r *router) trees(host string) []*radixNode {
+	node, _ := r.tree(host)
+	if node == r.node {
+		return []*radixNode{node}
+	}
+	return []*radixNode{node, r.node}
 }
 
 func (r *router) handler(resp *Response, req *Request) (HandlerFunc, error) {
-	method := parseMethod(req.Method())
-	handler, params, wild, err := r.node.Route(req.Path(), method)
+	method, ok := parseMethod(req.Method())
+	if !ok {
+		// Unknown method can only be handled by endpoints for any method
+		if handler, err := r.route(req, anyMethod); err == nil {
+			return handler, nil
+		}
+		return nil, ErrNotImplemented
+	}
+	return r.route(req, method)
+}
+
+// route finds handler of request with given method and sets params and
+// wild of the request
+func (r *router) route(req *Request, method methodType) (HandlerFunc, error) {
+	node, hostParams := r.tree(req.Host())
+	handler, params, wild, err := node.Route(req.Path(), method)
+	if err == ErrNotFound && node != r.node {
+		// Routes of any host serve paths not found for the host
+		handler, params, wild, err = r.node.Route(req.Path(), method)
+	}
 	if err != nil {
 		return nil, err
 	}
+	// Params from host and mounting engine are kept
+	for key, val := range hostParams {
+		if _, ok := params[key]; !ok {
+			params[key] = val
+		}
+	}
+	for key, val := range req.params {
+		if _, ok := params[key]; !ok {
+			params[key] = val
+		}
+	}
 	req.SetParams(params)
 	req.SetWild(wild)
 
 	return handler, nil
 }
+
+// allowed returns all methods registered for path of the request
+func (r *router) allowed(req *Request) []methodType {
+	for _, node := range r.trees(req.Host()) {
+		if methods := node.allowed(req.Path()); len(methods) > 0 {
+			return methods
+		}
+	}
+	return []methodType{}
+}
+
+// allowed returns all methods registered for given path
+func (node *radixNode) allowed(path string) []methodType {
+	methodMu.RLock()
+	count := len(methodNames)
+	methodMu.RUnlock()
+
+	methods := []methodType{}
+	for m := 0; m < count; m++ {
+		if _, _, _, err := node.route(path, methodType(m)); err == nil {
+			methods = append(methods, methodType(m))
+		}
+	}
+	return methods
+}
+
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// canonicalPath finds the registered form of a path which is not found,
+// ok is false if there is no such path
+func (r *router) canonicalPath(req *Request, trailingSlash bool, fixPath bool, caseInsensitive bool) (string, bool) {
+	for _, node := range r.trees(req.Host()) {
+		if path, ok := node.canonicalPath(req.Path(), trailingSlash, fixPath, caseInsensitive); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// canonicalPath finds the registered form of a path in the tree
+func (node *radixNode) canonicalPath(reqPath string, trailingSlash bool, fixPath bool, caseInsensitive bool) (string, bool) {
+	candidates := []string{reqPath}
+	if fixPath {
+		if cleaned := cleanPath(reqPath); cleaned != reqPath {
+			candidates = []string{cleaned, reqPath}
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate != reqPath && len(node.allowed(candidate)) > 0 {
+			return candidate, true
+		}
+		if trailingSlash {
+			if alt := toggleTrailingSlash(candidate); alt != "" && len(node.allowed(alt)) > 0 {
+				return alt, true
+			}
+		}
+		if caseInsensitive {
+			if fixed, ok := node.findCaseInsensitive(candidate); ok {
+				return fixed, true
+			}
+			if alt := toggleTrailingSlash(candidate); trailingSlash && alt != "" {
+				if fixed, ok := node.findCaseInsensitive(alt); ok {
+					return fixed, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// cleanPath removes duplicate slashes and dot segments from path, the trailing
+// slash is kept
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if p[len(p)-1] == '/' && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+func toggleTrailingSlash(p string) string {
+	if p == "/" || p == "" {
+		return ""
+	}
+	if strings.HasSuffix(p, "/") {
+		return p[:len(p)-1]
+	}
+	return p + "/"
+}
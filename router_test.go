@@ -0,0 +1,269 @@
+package cupcake
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(cc *Cupcake, method string, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	cc.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestRouteIntermediateNode(t *testing.T) {
+	cc := New()
+	ok := func(resp *Response, req *Request) { resp.String(http.StatusOK, "ok") }
+	cc.GET("/users/{id}/posts", ok)
+	cc.GET("/docs/api/v1", ok)
+
+	tests := []struct {
+		method string
+		target string
+		code   int
+		allow  string
+	}{
+		{http.MethodGet, "/users/5/posts", http.StatusOK, ""},
+		{http.MethodPut, "/users/5/posts", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{http.MethodGet, "/users/5", http.StatusNotFound, ""},
+		{http.MethodOptions, "/users/5", http.StatusNotFound, ""},
+		{http.MethodGet, "/docs/api", http.StatusNotFound, ""},
+		{http.MethodOptions, "/docs/api", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := serve(cc, test.method, test.target)
+		if w.Code != test.code {
+			t.Errorf("%s %s: got %d, want %d", test.method, test.target, w.Code, test.code)
+		}
+		if allow := w.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("%s %s: got Allow %q, want %q", test.method, test.target, allow, test.allow)
+		}
+	}
+}
+
+func TestRedirectTrailingSlash(t *testing.T) {
+	cc := New()
+	cc.RedirectTrailingSlash = true
+	ok := func(resp *Response, req *Request) { resp.String(http.StatusOK, "ok") }
+	cc.GET("/users/{id}/", ok)
+	cc.GET("/docs/", ok)
+	cc.POST("/items/{id:int}", ok)
+
+	tests := []struct {
+		method   string
+		target   string
+		code     int
+		location string
+	}{
+		{http.MethodGet, "/users/5", http.StatusMovedPermanently, "/users/5/"},
+		{http.MethodGet, "/users/5?tab=posts", http.StatusMovedPermanently, "/users/5/?tab=posts"},
+		{http.MethodGet, "/docs", http.StatusMovedPermanently, "/docs/"},
+		{http.MethodPost, "/items/7/", http.StatusPermanentRedirect, "/items/7"},
+		{http.MethodGet, "/users/5/", http.StatusOK, ""},
+	}
+	for _, test := range tests {
+		w := serve(cc, test.method, test.target)
+		if w.Code != test.code {
+			t.Errorf("%s %s: got %d, want %d", test.method, test.target, w.Code, test.code)
+		}
+		if location := w.Header().Get("Location"); location != test.location {
+			t.Errorf("%s %s: got Location %q, want %q", test.method, test.target, location, test.location)
+		}
+	}
+}
+
+func TestRouteConflict(t *testing.T) {
+	ok := func(resp *Response, req *Request) { resp.String(http.StatusOK, "ok") }
+	tests := []struct {
+		first    string
+		second   string
+		conflict bool
+	}{
+		{"/u/{id}", "/u/{id}", true},
+		{"/u/{id}", "/u/{name}", true},
+		{"/u/{id}", "/u/{name:[a-z]+}", true},
+		{"/u/{name:[a-z]+}", "/u/{id}", true},
+		{"/u/{id:int}", "/u/{n:[0-9a-z]+}", true},
+		{"/u/{id:int}", "/u/{n:int}", true},
+		{"/u/*", "/u/*", true},
+		{"/u/{id}", "/u/{id}/posts", false},
+		{"/u/{id:int}", "/u/{id:int}/posts", false},
+		{"/u/{name:[a-z]+}.json", "/u/{id:int}", false},
+		{"/u/{id}", "/u/*", false},
+		{"/u/{id}", "/u/me", false},
+	}
+	for _, test := range tests {
+		cc := New()
+		cc.PanicOnConflict = false
+		cc.GET(test.first, ok)
+		cc.GET(test.second, ok)
+		errs := cc.RouteErrors()
+		if got := len(errs) > 0; got != test.conflict {
+			t.Errorf("%s and %s: got conflict %v, want %v", test.first, test.second, got, test.conflict)
+			continue
+		}
+		if test.conflict && !errors.Is(errs[0], ErrRouteConflict) {
+			t.Errorf("%s and %s: got error %v, want ErrRouteConflict", test.first, test.second, errs[0])
+		}
+	}
+}
+
+func TestRoutePriority(t *testing.T) {
+	cc := New()
+	handler := func(name string) HandlerFunc {
+		return func(resp *Response, req *Request) { resp.String(http.StatusOK, name) }
+	}
+	cc.GET("/u/me", handler("static"))
+	cc.GET("/u/{id}", handler("param"))
+	cc.GET("/u/*", handler("wild"))
+	cc.GET("/v/{id:int}", handler("regex"))
+	cc.GET("/v/*", handler("wild"))
+
+	tests := []struct {
+		target string
+		body   string
+	}{
+		{"/u/me", "static"},
+		{"/u/5", "param"},
+		{"/u/5/posts", "wild"},
+		{"/v/5", "regex"},
+		{"/v/abc", "wild"},
+	}
+	for _, test := range tests {
+		w := serve(cc, http.MethodGet, test.target)
+		if body := w.Body.String(); body != test.body {
+			t.Errorf("GET %s: got %q, want %q", test.target, body, test.body)
+		}
+	}
+}
+
+func TestRouteConflictPanics(t *testing.T) {
+	cc := New()
+	ok := func(resp *Response, req *Request) { resp.String(http.StatusOK, "ok") }
+	cc.GET("/u/{id}", ok)
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on conflicting route")
+		}
+	}()
+	cc.GET("/u/{name:[a-z]+}", ok)
+}
+
+func TestRouteRegexMatchesWholeSegment(t *testing.T) {
+	cc := New()
+	ok := func(resp *Response, req *Request) { resp.String(http.StatusOK, "ok") }
+	cc.GET("/items/{id:[0-9]+}", ok)
+	cc.GET("/tags/{tag:go|rust}", ok)
+	cc.GET("/files/{name:[a-z]+}.json", ok)
+
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"/items/12", http.StatusOK},
+		{"/items/12abc", http.StatusNotFound},
+		{"/items/abc12", http.StatusNotFound},
+		{"/tags/go", http.StatusOK},
+		{"/tags/golang", http.StatusNotFound},
+		{"/tags/gorust", http.StatusNotFound},
+		{"/files/abc.json", http.StatusOK},
+		{"/files/abc1.json", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		if w := serve(cc, http.MethodGet, test.target); w.Code != test.code {
+			t.Errorf("GET %s: got %d, want %d", test.target, w.Code, test.code)
+		}
+	}
+}
+
+func TestAutoHandlerMiddlewares(t *testing.T) {
+	cc := New()
+	mark := func(name string) MiddlerWare {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(resp *Response, req *Request) {
+				resp.Header().Add("X-Middleware", name)
+				next(resp, req)
+			}
+		}
+	}
+	ok := func(resp *Response, req *Request) { resp.String(http.StatusOK, "ok") }
+	cc.MiddlerWare(mark("engine"))
+	cc.GET("/ping", ok)
+	v1 := cc.Group("/v1")
+	v1.MiddlerWare(mark("v1"))
+	v1.GET("/users", ok)
+
+	tests := []struct {
+		method      string
+		target      string
+		code        int
+		middlewares []string
+	}{
+		{http.MethodHead, "/ping", http.StatusOK, []string{"engine"}},
+		{http.MethodOptions, "/ping", http.StatusNoContent, []string{"engine"}},
+		{http.MethodHead, "/v1/users", http.StatusOK, []string{"engine", "v1"}},
+		{http.MethodOptions, "/v1/users", http.StatusNoContent, []string{"engine", "v1"}},
+	}
+	for _, test := range tests {
+		w := serve(cc, test.method, test.target)
+		if w.Code != test.code {
+			t.Errorf("%s %s: got %d, want %d", test.method, test.target, w.Code, test.code)
+		}
+		if got := w.Header()["X-Middleware"]; strings.Join(got, ",") != strings.Join(test.middlewares, ",") {
+			t.Errorf("%s %s: got middlewares %v, want %v", test.method, test.target, got, test.middlewares)
+		}
+	}
+}
+
+func TestHostRouting(t *testing.T) {
+	cc := New()
+	handler := func(name string) HandlerFunc {
+		return func(resp *Response, req *Request) {
+			resp.String(http.StatusOK, name+" "+req.Param("tenant")+req.Param("id"))
+		}
+	}
+	cc.GET("/", handler("default"))
+	cc.GET("/health", handler("health"))
+	api := cc.Host("api.example.com")
+	api.GET("/", handler("api"))
+	api.POST("/health", handler("api-health"))
+	tenant := cc.Host("{tenant}.example.com")
+	tenant.GET("/", handler("tenant"))
+	tenant.GET("/users/{id:int}", handler("user"))
+	cc.Host("{tenant}.{id:int}.shop.example.com").GET("/", handler("shop"))
+
+	tests := []struct {
+		method string
+		host   string
+		target string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "example.com", "/", http.StatusOK, "default "},
+		{http.MethodGet, "api.example.com", "/", http.StatusOK, "api "},
+		{http.MethodGet, "API.example.com:8080", "/", http.StatusOK, "api "},
+		{http.MethodGet, "acme.example.com", "/", http.StatusOK, "tenant acme"},
+		{http.MethodGet, "acme.example.com", "/users/7", http.StatusOK, "user acme7"},
+		{http.MethodGet, "acme.42.shop.example.com", "/", http.StatusOK, "shop acme42"},
+		{http.MethodGet, "acme.x.shop.example.com", "/", http.StatusOK, "default "},
+		{http.MethodGet, "acme.example.com", "/health", http.StatusOK, "health acme"},
+		{http.MethodPost, "api.example.com", "/health", http.StatusOK, "api-health "},
+		{http.MethodGet, "api.example.com", "/health", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "acme.example.com", "/users/x", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.target, nil)
+		r.Host = test.host
+		w := httptest.NewRecorder()
+		cc.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s %s%s: got %d, want %d", test.method, test.host, test.target, w.Code, test.code)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %s%s: got %q, want %q", test.method, test.host, test.target, w.Body.String(), test.body)
+		}
+	}
+}
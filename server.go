@@ -0,0 +1,216 @@
+package cupcake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lz-nsc/cupcake/log"
+)
+
+// Server runs a cupcake engine, and shuts it down gracefully on SIGINT
+// or SIGTERM
+type Server struct {
+	engine *Cupcake
+	srv    *http.Server
+	// Max time to wait for in-flight requests when shutting down
+	ShutdownTimeout time.Duration
+
+	mu         sync.Mutex
+	onStart    []func()
+	onShutdown []func()
+	shutdown   sync.Once
+	done       chan struct{}
+	// Closed when the server starts shutting down
+	stopping chan struct{}
+}
+
+type stoppingKey struct{}
+
+func NewServer(engine *Cupcake) *Server {
+	return &Server{
+		engine:          engine,
+		ShutdownTimeout: 10 * time.Second,
+		onShutdown:      []func(){closeDefaultDBEngine},
+		done:            make(chan struct{}),
+		stopping:        make(chan struct{}),
+	}
+}
+
+// OnStart adds hook called after the server starts listening
+func (s *Server) OnStart(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onStart = append(s.onStart, fn)
+}
+
+// OnShutdown adds hook called after in-flight requests are drained,
+// the default ORM engine is closed by default
+func (s *Server) OnShutdown(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
+// Run listens on address and serves until the server is shut down,
+// nil is returned if the server is shut down gracefully. HTTPS is served
+// if the engine is created with TLS options
+func (s *Server) Run(address string) error {
+	opts := s.engine.options
+	if opts.CertFile != "" || opts.TLSConfig != nil {
+		return s.RunTLS(address, opts.CertFile, opts.KeyFile)
+	}
+	if address == "" {
+		address = ":http"
+	}
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.serve(ln, false, "", "")
+}
+
+// RunTLS works like Run but serves HTTPS with given cert and key files
+func (s *Server) RunTLS(address string, certFile string, keyFile string) error {
+	if address == "" {
+		address = ":https"
+	}
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.serve(ln, true, certFile, keyFile)
+}
+
+// RunUnix works like Run but listens on a unix socket, stale socket file
+// is removed before listening
+func (s *Server) RunUnix(path string) error {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	return s.serve(ln, false, "", "")
+}
+
+// RunListener works like Run but serves on the given listener, such as
+// listener from systemd socket activation
+func (s *Server) RunListener(ln net.Listener) error {
+	opts := s.engine.options
+	useTLS := opts.CertFile != "" || opts.TLSConfig != nil
+	return s.serve(ln, useTLS, opts.CertFile, opts.KeyFile)
+}
+
+// Shutdown stops accepting new connections, waits for in-flight requests
+// until ctx is done, then calls the shutdown hooks. Connections still open
+// when ctx is done are closed before the hooks are called
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	srv := s.srv
+	s.mu.Unlock()
+	if srv == nil {
+		return errors.New("server is not running")
+	}
+
+	var err error
+	s.shutdown.Do(func() {
+		log.Info("shutting down server")
+		err = srv.Shutdown(ctx)
+		if err != nil {
+			log.Errorf("failed to drain requests: %v", err)
+			srv.Close()
+		}
+		s.mu.Lock()
+		hooks := s.onShutdown
+		s.mu.Unlock()
+		for _, hook := range hooks {
+			hook()
+		}
+		close(s.done)
+	})
+	return err
+}
+
+func (s *Server) serve(ln net.Listener, useTLS bool, certFile string, keyFile string) error {
+	if errs := s.engine.RouteErrors(); len(errs) > 0 {
+		ln.Close()
+		return fmt.Errorf("%d route(s) failed to register, first err: %w", len(errs), errs[0])
+	}
+
+	s.mu.Lock()
+	if s.srv != nil {
+		s.mu.Unlock()
+		ln.Close()
+		return errors.New("server is already running")
+	}
+	s.srv = s.newHTTPServer(ln.Addr().String())
+	srv := s.srv
+	hooks := s.onStart
+	s.mu.Unlock()
+
+	log.Infof("cupcake server is listening on %s", ln.Addr())
+	for _, hook := range hooks {
+		hook()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	errCh := make(chan error, 1)
+	go func() {
+		if useTLS {
+			errCh <- srv.ServeTLS(ln, certFile, keyFile)
+			return
+		}
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != http.ErrServerClosed {
+			return err
+		}
+		// Shutdown is called by others, wait for it to finish
+		<-s.done
+		return nil
+	case sig := <-quit:
+		log.Infof("received signal %s", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+		defer cancel()
+		return s.Shutdown(ctx)
+	}
+}
+
+func (s *Server) newHTTPServer(address string) *http.Server {
+	opts := s.engine.options
+	srv := &http.Server{
+		Addr:              address,
+		Handler:           s.engine,
+		ReadTimeout:       opts.ReadTimeout,
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		MaxHeaderBytes:    opts.MaxHeaderBytes,
+		TLSConfig:         opts.TLSConfig,
+		// Long-lived requests can watch Request.ShuttingDown
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), stoppingKey{}, s.stopping)
+		},
+	}
+	srv.RegisterOnShutdown(func() {
+		close(s.stopping)
+	})
+	return srv
+}
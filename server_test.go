@@ -0,0 +1,99 @@
+package cupcake
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, cc *Cupcake) (*Server, string, chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(cc)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.RunListener(ln)
+	}()
+	return srv, "http://" + ln.Addr().String(), errCh
+}
+
+func TestShutdownClosesEventStreams(t *testing.T) {
+	cc := New()
+	started := make(chan struct{})
+	cc.GET("/events", func(resp *Response, req *Request) {
+		stream, err := resp.SSE()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		close(started)
+		<-stream.Done()
+	})
+	srv, addr, errCh := startServer(t, cc)
+
+	go func() {
+		res, err := http.Get(addr + "/events")
+		if err == nil {
+			defer res.Body.Close()
+			buf := make([]byte, 1)
+			res.Body.Read(buf)
+		}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("shutdown took %v", elapsed)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShutdownClosesConnectionsBeforeHooks(t *testing.T) {
+	cc := New()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	cc.GET("/slow", func(resp *Response, req *Request) {
+		close(started)
+		<-release
+	})
+	srv, addr, _ := startServer(t, cc)
+
+	clientErr := make(chan error, 1)
+	go func() {
+		res, err := http.Get(addr + "/slow")
+		if err == nil {
+			res.Body.Close()
+		}
+		clientErr <- err
+	}()
+	<-started
+
+	closedBeforeHook := false
+	srv.OnShutdown(func() {
+		select {
+		case err := <-clientErr:
+			closedBeforeHook = err != nil
+		case <-time.After(time.Second):
+		}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !closedBeforeHook {
+		t.Error("connection is still open when hooks are called")
+	}
+}
@@ -0,0 +1,87 @@
+package cupcake
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lz-nsc/cupcake/config"
+	"github.com/lz-nsc/cupcake/log"
+)
+
+var (
+	middlewareMu sync.RWMutex
+	// Middlewares which can be enabled by name in settings
+	namedMiddlewares = map[string]MiddlerWare{}
+)
+
+// RegisterMiddleware registers middleware with name so that it can be enabled
+// in settings, middlewares in package middlewares are registered when the
+// package is imported
+func RegisterMiddleware(name string, m MiddlerWare) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	namedMiddlewares[name] = m
+}
+
+// NewWithSettings creates engine set up with the settings, which include
+// log level, database of the default ORM engine, templates, static dirs
+// and middlewares
+// settings, err := config.Load("settings.yaml", "prod")
+// cc, err := cupcake.NewWithSettings(settings)
+func NewWithSettings(settings *config.Settings, opts ...Option) (*Cupcake, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+
+	middlewares := make([]MiddlerWare, 0, len(settings.Middlewares))
+	middlewareMu.RLock()
+	for _, name := range settings.Middlewares {
+		m, ok := namedMiddlewares[name]
+		if !ok {
+			middlewareMu.RUnlock()
+			return nil, fmt.Errorf("unknown middleware '%s', registered: %s", name, registeredMiddlewares())
+		}
+		middlewares = append(middlewares, m)
+	}
+	middlewareMu.RUnlock()
+
+	level, _ := log.ParseLevel(settings.LogLevel)
+	log.SetLevel(level)
+
+	if defaultDBEngine != nil && (defaultDBDriver != settings.Database.Driver || defaultDBSource != settings.Database.DSN) {
+		closeDefaultDBEngine()
+	}
+	defaultDBDriver = settings.Database.Driver
+	defaultDBSource = settings.Database.DSN
+
+	cc := New(opts...)
+	cc.settings = settings
+	if settings.Templates != "" {
+		cc.LoadTemplates(settings.Templates)
+	}
+	for _, static := range settings.Statics {
+		cc.Static(static.Pattern, static.Dir)
+	}
+	cc.MiddlerWare(middlewares...)
+	return cc, nil
+}
+
+// Settings returns settings the engine is created with, nil if the engine
+// is not created by NewWithSettings
+func (cc *Cupcake) Settings() *config.Settings {
+	return cc.settings
+}
+
+func registeredMiddlewares() string {
+	names := make([]string, 0, len(namedMiddlewares))
+	for name := range namedMiddlewares {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "none, import github.com/lz-nsc/cupcake/middlewares to register the builtin ones"
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,204 @@
+package cupcake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrStreamingUnsupported = errors.New("streaming unsupported")
+	ErrStreamClosed         = errors.New("event stream closed")
+)
+
+// Event is a message of server-sent events, Data which is not string or
+// []byte is encoded in JSON
+type Event struct {
+	ID    string
+	Event string
+	Data  interface{}
+	// Reconnection time for client, 0 means not to send it
+	Retry time.Duration
+}
+
+// EventStream writes server-sent events, it is safe to send events from
+// multiple goroutines
+type EventStream struct {
+	mu     sync.Mutex
+	resp   *Response
+	ctx    context.Context
+	cancel context.CancelFunc
+	// ID of the last event received by client before reconnecting
+	lastEventID string
+}
+
+// Flush sends data written so far to client, if the writer supports it.
+// Buffered body is written before flushing and buffering is disabled, so
+// the body can be streamed after it
+func (resp *Response) Flush() {
+	flusher, ok := resp.writer.(http.Flusher)
+	if !ok {
+		return
+	}
+	resp.unbuffer()
+	resp.writeHeader()
+	flusher.Flush()
+}
+
+// SSE starts streaming server-sent events, buffered body is flushed and
+// buffering is disabled. The stream is closed when client disconnects, the
+// server shuts down or the route handler returns, before outer middlewares
+// continue
+func (resp *Response) SSE() (*EventStream, error) {
+	if _, ok := resp.writer.(http.Flusher); !ok {
+		return nil, ErrStreamingUnsupported
+	}
+
+	ctx := context.Background()
+	lastEventID := ""
+	if resp.req != nil {
+		ctx = resp.req.Context()
+		lastEventID = resp.req.Header.Get("Last-Event-ID")
+	}
+	stream := &EventStream{resp: resp, lastEventID: lastEventID}
+	stream.ctx, stream.cancel = context.WithCancel(ctx)
+	resp.stream = stream
+	if resp.req != nil {
+		if stopping := shuttingDown(resp.req); stopping != nil {
+			// Streams do not hold up shutdown of server
+			go func() {
+				select {
+				case <-stopping:
+					stream.Close()
+				case <-stream.Done():
+				}
+			}()
+		}
+	}
+
+	header := resp.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// Disable buffering of proxies like nginx
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+	resp.Status(http.StatusOK).Flush()
+	return stream, nil
+}
+
+// LastEventID returns Last-Event-ID header sent by reconnecting client, so
+// the stream can be resumed after that event
+func (stream *EventStream) LastEventID() string {
+	return stream.lastEventID
+}
+
+// Done returns a channel closed when the stream is closed or client
+// disconnects
+func (stream *EventStream) Done() <-chan struct{} {
+	return stream.ctx.Done()
+}
+
+// Send writes the event and flushes it to client
+func (stream *EventStream) Send(event Event) error {
+	var msg strings.Builder
+	if event.ID != "" {
+		writeField(&msg, "id", event.ID)
+	}
+	if event.Event != "" {
+		writeField(&msg, "event", event.Event)
+	}
+	if event.Retry > 0 {
+		writeField(&msg, "retry", fmt.Sprint(event.Retry.Milliseconds()))
+	}
+	var data string
+	switch val := event.Data.(type) {
+	case nil:
+	case string:
+		data = val
+	case []byte:
+		data = string(val)
+	default:
+		encoded, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+	if event.Data != nil {
+		for _, line := range strings.Split(data, "\n") {
+			writeField(&msg, "data", line)
+		}
+	}
+	msg.WriteString("\n")
+	return stream.write(msg.String())
+}
+
+// Comment writes a comment line, which is ignored by client
+func (stream *EventStream) Comment(text string) error {
+	var msg strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		msg.WriteString(": " + line + "\n")
+	}
+	msg.WriteString("\n")
+	return stream.write(msg.String())
+}
+
+// Heartbeat sends comments at given interval to keep the connection alive
+// until the stream is closed
+func (stream *EventStream) Heartbeat(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stream.Done():
+				return
+			case <-ticker.C:
+				if err := stream.Comment("heartbeat"); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Close stops the stream and its heartbeats, events sent after it return
+// ErrStreamClosed
+func (stream *EventStream) Close() {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	stream.cancel()
+}
+
+func (stream *EventStream) write(msg string) error {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if stream.ctx.Err() != nil {
+		return ErrStreamClosed
+	}
+	if _, err := stream.resp.write([]byte(msg)); err != nil {
+		stream.cancel()
+		return err
+	}
+	stream.resp.Flush()
+	return nil
+}
+
+// closeStream closes event stream started on the response, if any
+func (resp *Response) closeStream() {
+	if resp.stream != nil {
+		resp.stream.Close()
+	}
+}
+
+// writeField writes a field of event, line breaks in value are removed
+func writeField(msg *strings.Builder, name string, value string) {
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	msg.WriteString(name + ": " + value + "\n")
+}
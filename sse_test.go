@@ -0,0 +1,69 @@
+package cupcake
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFlushBufferedResponse(t *testing.T) {
+	cc := New()
+	cc.BufferResponses = true
+	cc.GET("/stream", func(resp *Response, req *Request) {
+		resp.String(http.StatusOK, "hello ")
+		resp.Flush()
+		if _, err := resp.Writer().Write([]byte("world")); err != nil {
+			t.Error(err)
+		}
+	})
+	srv := httptest.NewServer(cc)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello world" || res.ContentLength != -1 {
+		t.Errorf("got %q with Content-Length %d", body, res.ContentLength)
+	}
+}
+
+func TestSSEBufferedResponse(t *testing.T) {
+	cc := New()
+	cc.BufferResponses = true
+	cc.GET("/events", func(resp *Response, req *Request) {
+		stream, err := resp.SSE()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		stream.Send(Event{ID: "1", Data: "a"})
+		stream.Send(Event{ID: "2", Data: "b"})
+	})
+	srv := httptest.NewServer(cc)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if contentType := res.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("got Content-Type %q", contentType)
+	}
+	reader := bufio.NewReader(res.Body)
+	want := []string{"id: 1\n", "data: a\n", "\n", "id: 2\n", "data: b\n", "\n"}
+	for _, line := range want {
+		got, err := reader.ReadString('\n')
+		if err != nil || got != line {
+			t.Fatalf("got %q, %v, want %q", got, err, line)
+		}
+	}
+}
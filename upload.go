@@ -0,0 +1,169 @@
+package cupcake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Size of multipart form kept in memory by default, the rest of files are
+// stored in temporary files on disk
+const defaultMultipartMemory = 32 << 20
+
+var (
+	ErrFileTooLarge = errors.New("file too large")
+	ErrFileType     = errors.New("file type not allowed")
+
+	fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+)
+
+// FileLimits limits files uploaded with multipart form
+type FileLimits struct {
+	// Max size of each file in bytes, 0 means no limit
+	MaxSize int64
+	// Allowed media types detected from file content, such as "image/png"
+	// or "image/*", empty means any type
+	AllowedTypes []string
+}
+
+// Check checks the file against the limits
+func (limits FileLimits) Check(fh *multipart.FileHeader) error {
+	if limits.MaxSize > 0 && fh.Size > limits.MaxSize {
+		return fmt.Errorf("%w: %s is %d bytes, max %d bytes", ErrFileTooLarge, fh.Filename, fh.Size, limits.MaxSize)
+	}
+	if len(limits.AllowedTypes) == 0 {
+		return nil
+	}
+	mediaType, err := detectFileType(fh)
+	if err != nil {
+		return err
+	}
+	for _, allowed := range limits.AllowedTypes {
+		if allowed == mediaType || allowed == "*/*" ||
+			(strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, allowed[:len(allowed)-1])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is %s", ErrFileType, fh.Filename, mediaType)
+}
+
+// detectFileType detects media type of the file by its first 512 bytes
+func detectFileType(fh *multipart.FileHeader) (string, error) {
+	file, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	return mediaType, err
+}
+
+// File returns the first file uploaded with given form name, after checking
+// it against the file limits of the engine
+func (r *Request) File(name string) (*multipart.FileHeader, error) {
+	files, err := r.Files(name)
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+// Files returns all files uploaded with given form name, after checking
+// them against the file limits of the engine
+func (r *Request) Files(name string) ([]*multipart.FileHeader, error) {
+	if err := r.parseMultipartForm(); err != nil {
+		return nil, err
+	}
+	files := r.req.MultipartForm.File[name]
+	if len(files) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	for _, fh := range files {
+		if err := r.fileLimits.Check(fh); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// SaveFile streams uploaded file to dst, parent directories of dst are
+// created if they do not exist
+func (r *Request) SaveFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (r *Request) parseMultipartForm() error {
+	if r.req.MultipartForm != nil {
+		return nil
+	}
+	return r.bodyError(r.req.ParseMultipartForm(r.multipartMemory))
+}
+
+// parseMultipart fills obj with values and files of multipart form, fields
+// of type *multipart.FileHeader or []*multipart.FileHeader are filled with files
+func (r Request) parseMultipart(obj interface{}) error {
+	if err := r.parseMultipartForm(); err != nil {
+		return err
+	}
+	objVal := reflect.ValueOf(obj)
+	if objVal.Kind() != reflect.Ptr || objVal.IsNil() || objVal.Elem().Kind() != reflect.Struct {
+		return errors.New("given obj is not pointer to a struct")
+	}
+	form := r.req.MultipartForm
+	if errs := bindForm(r.req.Form, form.File, r.fileLimits, objVal.Elem(), nil); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// isFileType reports whether the type can be filled with uploaded files
+func isFileType(typ reflect.Type) bool {
+	return typ == fileHeaderType || (typ.Kind() == reflect.Slice && typ.Elem() == fileHeaderType)
+}
+
+// setFiles fills field with uploaded files after checking limits
+func setFiles(field reflect.Value, files []*multipart.FileHeader, limits FileLimits) error {
+	if len(files) == 0 {
+		return nil
+	}
+	for _, fh := range files {
+		if err := limits.Check(fh); err != nil {
+			return err
+		}
+	}
+	if field.Type() == fileHeaderType {
+		field.Set(reflect.ValueOf(files[0]))
+		return nil
+	}
+	field.Set(reflect.ValueOf(files))
+	return nil
+}
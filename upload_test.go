@@ -0,0 +1,172 @@
+package cupcake
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+type uploadFile struct {
+	field   string
+	name    string
+	content []byte
+}
+
+// multipartRequest builds a POST request with multipart form of given
+// values and files
+func multipartRequest(t *testing.T, values map[string]string, files []uploadFile) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, val := range values {
+		if err := writer.WriteField(key, val); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range files {
+		part, err := writer.CreateFormFile(file.field, file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(file.content)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	return r
+}
+
+type uploadForm struct {
+	Name   string                  `form:"name"`
+	Avatar *multipart.FileHeader   `form:"avatar"`
+	Photos []*multipart.FileHeader `form:"photos"`
+}
+
+func TestBindMultipart(t *testing.T) {
+	cc := New()
+	var form uploadForm
+	var bindErr error
+	cc.POST("/upload", func(resp *Response, req *Request) {
+		bindErr = req.Bind(&form)
+	})
+	r := multipartRequest(t, map[string]string{"name": "cupcake"}, []uploadFile{
+		{"avatar", "a.png", pngHeader},
+		{"photos", "1.png", pngHeader},
+		{"photos", "2.png", pngHeader},
+	})
+	cc.ServeHTTP(httptest.NewRecorder(), r)
+	if bindErr != nil {
+		t.Fatal(bindErr)
+	}
+	if form.Name != "cupcake" || form.Avatar == nil || form.Avatar.Filename != "a.png" || len(form.Photos) != 2 {
+		t.Errorf("got %+v", form)
+	}
+}
+
+func TestMultipartFileLimits(t *testing.T) {
+	tests := []struct {
+		limits FileLimits
+		file   uploadFile
+		err    error
+	}{
+		{FileLimits{MaxSize: 16}, uploadFile{"avatar", "a.png", pngHeader}, nil},
+		{FileLimits{MaxSize: 16}, uploadFile{"avatar", "a.png", bytes.Repeat(pngHeader, 4)}, ErrFileTooLarge},
+		{FileLimits{AllowedTypes: []string{"image/*"}}, uploadFile{"avatar", "a.png", pngHeader}, nil},
+		{FileLimits{AllowedTypes: []string{"image/*"}}, uploadFile{"avatar", "a.png", []byte("plain text")}, ErrFileType},
+		{FileLimits{AllowedTypes: []string{"image/png"}}, uploadFile{"avatar", "a.gif", []byte("GIF89a")}, ErrFileType},
+	}
+	for idx, test := range tests {
+		var bindErr, fileErr error
+		cc := New(WithFileLimits(test.limits))
+		cc.POST("/upload", func(resp *Response, req *Request) {
+			var form uploadForm
+			bindErr = req.Bind(&form)
+			_, fileErr = req.File("avatar")
+		})
+		cc.ServeHTTP(httptest.NewRecorder(), multipartRequest(t, nil, []uploadFile{test.file}))
+
+		if !errors.Is(fileErr, test.err) {
+			t.Errorf("case %d: File got %v, want %v", idx, fileErr, test.err)
+		}
+		var bindErrs BindErrors
+		if test.err == nil {
+			if bindErr != nil {
+				t.Errorf("case %d: Bind got %v", idx, bindErr)
+			}
+		} else if !errors.As(bindErr, &bindErrs) || bindErrs[0].Source != "file" || !errors.Is(bindErrs[0], test.err) {
+			t.Errorf("case %d: Bind got %v, want %v", idx, bindErr, test.err)
+		}
+	}
+}
+
+func TestMultipartMemory(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 4096)
+	cc := New(WithMultipartMemory(1024))
+	dir := t.TempDir()
+	cc.POST("/upload", func(resp *Response, req *Request) {
+		fh, err := req.File("doc")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		// Files beyond the memory limit are stored on disk
+		file, err := fh.Open()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer file.Close()
+		if _, ok := file.(*os.File); !ok {
+			t.Errorf("got %T, want file on disk", file)
+		}
+		if err := req.SaveFile(fh, filepath.Join(dir, "nested", "doc.txt")); err != nil {
+			t.Error(err)
+		}
+		if _, err := req.File("missing"); err != http.ErrMissingFile {
+			t.Errorf("got %v, want %v", err, http.ErrMissingFile)
+		}
+	})
+	cc.ServeHTTP(httptest.NewRecorder(), multipartRequest(t, nil, []uploadFile{{"doc", "doc.txt", content}}))
+
+	saved, err := ioutil.ReadFile(filepath.Join(dir, "nested", "doc.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(saved, content) {
+		t.Errorf("saved %d bytes, want %d", len(saved), len(content))
+	}
+}
+
+func TestMultipartBodyTooLarge(t *testing.T) {
+	var fileErr error
+	cc := New(WithMaxBodySize(1024))
+	cc.POST("/upload", func(resp *Response, req *Request) {
+		_, fileErr = req.File("doc")
+		resp.Status(http.StatusOK)
+	})
+	r := multipartRequest(t, nil, []uploadFile{{"doc", "doc.txt", []byte(strings.Repeat("x", 4096))}})
+	w := httptest.NewRecorder()
+	cc.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	// Body of unknown length fails when it is read
+	r = multipartRequest(t, nil, []uploadFile{{"doc", "doc.txt", []byte(strings.Repeat("x", 4096))}})
+	r.ContentLength = -1
+	cc.ServeHTTP(httptest.NewRecorder(), r)
+	if !errors.Is(fileErr, ErrBodyTooLarge) {
+		t.Errorf("got %v, want %v", fileErr, ErrBodyTooLarge)
+	}
+}
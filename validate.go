@@ -0,0 +1,356 @@
+package cupcake
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidatorFunc reports whether field satisfies the rule with given param,
+// e.g. param is "1" for rule min=1
+type ValidatorFunc func(field reflect.Value, param string) bool
+
+// FieldError describes a field failed validation
+type FieldError struct {
+	Field   string      `json:"field"`
+	Tag     string      `json:"tag"`
+	Param   string      `json:"param,omitempty"`
+	Value   interface{} `json:"value,omitempty"`
+	Message string      `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors contains all fields failed validation
+type ValidationErrors []*FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ErrInvalidRule is returned by Validate if a validate tag has unknown rule
+// or invalid param
+var ErrInvalidRule = errors.New("invalid validation rule")
+
+var (
+	validatorMu sync.RWMutex
+	validators  = map[string]ValidatorFunc{
+		"min":     func(v reflect.Value, p string) bool { return compare(v, p, func(a, b float64) bool { return a >= b }) },
+		"max":     func(v reflect.Value, p string) bool { return compare(v, p, func(a, b float64) bool { return a <= b }) },
+		"gt":      func(v reflect.Value, p string) bool { return compare(v, p, func(a, b float64) bool { return a > b }) },
+		"gte":     func(v reflect.Value, p string) bool { return compare(v, p, func(a, b float64) bool { return a >= b }) },
+		"lt":      func(v reflect.Value, p string) bool { return compare(v, p, func(a, b float64) bool { return a < b }) },
+		"lte":     func(v reflect.Value, p string) bool { return compare(v, p, func(a, b float64) bool { return a <= b }) },
+		"len":     func(v reflect.Value, p string) bool { return compare(v, p, func(a, b float64) bool { return a == b }) },
+		"eq":      func(v reflect.Value, p string) bool { return fmt.Sprint(v.Interface()) == p },
+		"ne":      func(v reflect.Value, p string) bool { return fmt.Sprint(v.Interface()) != p },
+		"oneof":   validateOneOf,
+		"email":   matchString(regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)),
+		"alpha":   matchString(regexp.MustCompile(`^[a-zA-Z]+$`)),
+		"alnum":   matchString(regexp.MustCompile(`^[a-zA-Z0-9]+$`)),
+		"numeric": matchString(regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`)),
+		"uuid":    matchString(uuidRex),
+		"url":     validateURL,
+		"contains": func(v reflect.Value, p string) bool {
+			return v.Kind() == reflect.String && strings.Contains(v.String(), p)
+		},
+	}
+	// Rules whose param must be a number
+	numericRules = map[string]bool{"min": true, "max": true, "gt": true, "gte": true, "lt": true, "lte": true, "len": true}
+
+	// Parsed rules of struct types, reflect.Type -> *structRules
+	rulesCache sync.Map
+)
+
+// structRules holds validate tags of a struct type parsed into rules
+type structRules struct {
+	fields []fieldRules
+	err    error
+}
+
+type fieldRules struct {
+	index int
+	name  string
+	// Whether validate tag is "-"
+	skip  bool
+	rules []rule
+}
+
+type rule struct {
+	name  string
+	param string
+	fn    ValidatorFunc
+}
+
+// RegisterValidator registers validator which can be used in validate tag
+// with given name
+func RegisterValidator(name string, fn ValidatorFunc) {
+	validatorMu.Lock()
+	defer validatorMu.Unlock()
+	validators[name] = fn
+	// Rules parsed before may refer to the validator
+	rulesCache.Range(func(key, _ interface{}) bool {
+		rulesCache.Delete(key)
+		return true
+	})
+}
+
+// Validate checks fields of struct with rules in their validate tags:
+//
+//	type User struct {
+//		Name  string `json:"name" validate:"required,max=20"`
+//		Age   int    `json:"age" validate:"min=1,max=120"`
+//		Email string `json:"email" validate:"omitempty,email"`
+//		Role  string `json:"role" validate:"oneof=admin user"`
+//	}
+//
+// Nested structs and slices of structs are checked recursively, and all
+// failed fields are returned as ValidationErrors. Tags are parsed once for
+// each type, an error wrapping ErrInvalidRule is returned if a tag has unknown
+// rule or invalid param
+func Validate(obj interface{}) error {
+	val := reflect.ValueOf(obj)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot validate %T, should be struct", obj)
+	}
+	errs := ValidationErrors{}
+	if err := validateStruct(val, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(val reflect.Value, prefix string, errs *ValidationErrors) error {
+	parsed := rulesOf(val.Type())
+	if parsed.err != nil {
+		return parsed.err
+	}
+	for _, field := range parsed.fields {
+		if field.skip {
+			continue
+		}
+		name := prefix + field.name
+		fieldVal := val.Field(field.index)
+		if !validateField(fieldVal, name, field.rules, errs) {
+			continue
+		}
+		if err := validateNested(fieldVal, name, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rulesOf returns parsed rules of the struct type
+func rulesOf(typ reflect.Type) *structRules {
+	if cached, ok := rulesCache.Load(typ); ok {
+		return cached.(*structRules)
+	}
+	parsed := &structRules{}
+	for idx := 0; idx < typ.NumField(); idx++ {
+		fieldType := typ.Field(idx)
+		if fieldType.PkgPath != "" {
+			continue
+		}
+		field := fieldRules{index: idx, name: fieldName(fieldType)}
+		tag := fieldType.Tag.Get("validate")
+		if tag == "-" {
+			field.skip = true
+		} else if tag != "" {
+			rules, err := parseRules(tag)
+			if err != nil {
+				parsed.err = fmt.Errorf("%w on field %s of %s: %s", ErrInvalidRule, fieldType.Name, typ, err.Error())
+				break
+			}
+			field.rules = rules
+		}
+		parsed.fields = append(parsed.fields, field)
+	}
+	rulesCache.Store(typ, parsed)
+	return parsed
+}
+
+// parseRules parses validate tag into rules, returns error if there is
+// unknown rule or invalid param
+func parseRules(tag string) ([]rule, error) {
+	rules := []rule{}
+	for _, str := range strings.Split(tag, ",") {
+		r := rule{name: str}
+		if idx := strings.Index(str, "="); idx >= 0 {
+			r.name, r.param = str[:idx], str[idx+1:]
+		}
+		switch r.name {
+		case "":
+			continue
+		case "omitempty", "required":
+			rules = append(rules, r)
+			continue
+		}
+
+		validatorMu.RLock()
+		fn, ok := validators[r.name]
+		validatorMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown rule '%s'", r.name)
+		}
+		if numericRules[r.name] {
+			if _, err := strconv.ParseFloat(r.param, 64); err != nil {
+				return nil, fmt.Errorf("rule '%s' needs a number, got '%s'", r.name, r.param)
+			}
+		}
+		r.fn = fn
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// validateField checks rules on field, returns false if the field is
+// skipped or fails
+func validateField(field reflect.Value, name string, rules []rule, errs *ValidationErrors) bool {
+	empty := field.IsZero()
+	for _, r := range rules {
+		switch r.name {
+		case "omitempty":
+			if empty {
+				return false
+			}
+			continue
+		case "required":
+			if empty {
+				*errs = append(*errs, newFieldError(name, r.name, r.param, nil))
+				return false
+			}
+			continue
+		}
+
+		// Check value pointed by pointer, nil pointer passes the rules
+		target := field
+		for target.Kind() == reflect.Ptr {
+			if target.IsNil() {
+				break
+			}
+			target = target.Elem()
+		}
+		if target.Kind() == reflect.Ptr {
+			continue
+		}
+		if !r.fn(target, r.param) {
+			*errs = append(*errs, newFieldError(name, r.name, r.param, target.Interface()))
+			return false
+		}
+	}
+	return true
+}
+
+// validateNested checks struct, pointer to struct and slice of structs
+func validateNested(field reflect.Value, name string, errs *ValidationErrors) error {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+	switch field.Kind() {
+	case reflect.Struct:
+		if field.Type() == reflect.TypeOf(time.Time{}) {
+			return nil
+		}
+		return validateStruct(field, name+".", errs)
+	case reflect.Slice, reflect.Array:
+		for idx := 0; idx < field.Len(); idx++ {
+			if err := validateNested(field.Index(idx), fmt.Sprintf("%s[%d]", name, idx), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newFieldError(name string, tag string, param string, value interface{}) *FieldError {
+	msg := fmt.Sprintf("%s failed on '%s' validation", name, tag)
+	if param != "" {
+		msg = fmt.Sprintf("%s failed on '%s=%s' validation", name, tag, param)
+	}
+	return &FieldError{
+		Field:   name,
+		Tag:     tag,
+		Param:   param,
+		Value:   value,
+		Message: msg,
+	}
+}
+
+// fieldName returns json name of the field if it has one
+func fieldName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// compare compares number, or length of string, slice and map with param
+func compare(v reflect.Value, param string, cmp func(float64, float64) bool) bool {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp(float64(v.Int()), limit)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp(float64(v.Uint()), limit)
+	case reflect.Float32, reflect.Float64:
+		return cmp(v.Float(), limit)
+	case reflect.String:
+		return cmp(float64(utf8.RuneCountInString(v.String())), limit)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return cmp(float64(v.Len()), limit)
+	}
+	return false
+}
+
+func validateOneOf(v reflect.Value, param string) bool {
+	str := fmt.Sprint(v.Interface())
+	for _, option := range strings.Fields(param) {
+		if str == option {
+			return true
+		}
+	}
+	return false
+}
+
+func validateURL(v reflect.Value, _ string) bool {
+	if v.Kind() != reflect.String {
+		return false
+	}
+	u, err := url.Parse(v.String())
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func matchString(rex *regexp.Regexp) ValidatorFunc {
+	return func(v reflect.Value, _ string) bool {
+		return v.Kind() == reflect.String && rex.MatchString(v.String())
+	}
+}
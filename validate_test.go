@@ -0,0 +1,138 @@
+package cupcake
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	type Address struct {
+		City string `json:"city" validate:"required"`
+	}
+	type User struct {
+		Name      string    `json:"name" validate:"required,max=5"`
+		Age       int       `json:"age" validate:"min=1,max=120"`
+		Email     string    `json:"email" validate:"omitempty,email"`
+		Role      string    `json:"role" validate:"oneof=admin user"`
+		Nick      *string   `json:"nick" validate:"min=2"`
+		Address   Address   `json:"address"`
+		Addresses []Address `json:"addresses"`
+		Ignored   string    `validate:"-"`
+	}
+	nick := "x"
+
+	tests := []struct {
+		user   User
+		fields []string
+	}{
+		{User{Name: "cake", Age: 3, Role: "user", Address: Address{"A"}}, nil},
+		{User{Name: "cupcake", Age: 0, Email: "bad", Role: "guest", Nick: &nick, Address: Address{"A"}},
+			[]string{"name", "age", "email", "role", "nick"}},
+		{User{Age: 3, Role: "admin", Addresses: []Address{{"A"}, {}}},
+			[]string{"name", "address.city", "addresses[1].city"}},
+	}
+	for idx, test := range tests {
+		err := Validate(&test.user)
+		if test.fields == nil {
+			if err != nil {
+				t.Errorf("case %d: got %v, want nil", idx, err)
+			}
+			continue
+		}
+		var errs ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("case %d: got %v, want ValidationErrors", idx, err)
+			continue
+		}
+		fields := []string{}
+		for _, fieldErr := range errs {
+			fields = append(fields, fieldErr.Field)
+		}
+		if !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("case %d: got fields %v, want %v", idx, fields, test.fields)
+		}
+	}
+}
+
+func TestValidateInvalidRule(t *testing.T) {
+	type Misspelled struct {
+		Name string `validate:"requird"`
+	}
+	type BadParam struct {
+		Age int `validate:"min=one"`
+	}
+	type Nested struct {
+		Inner []BadParam
+	}
+
+	tests := []struct {
+		obj interface{}
+		msg string
+	}{
+		{&Misspelled{Name: "a"}, "unknown rule 'requird'"},
+		{&BadParam{Age: 1}, "rule 'min' needs a number"},
+		{&Nested{Inner: []BadParam{{}}}, "rule 'min' needs a number"},
+	}
+	for _, test := range tests {
+		// Check twice as parsed rules are cached
+		for i := 0; i < 2; i++ {
+			err := Validate(test.obj)
+			if !errors.Is(err, ErrInvalidRule) || !strings.Contains(err.Error(), test.msg) {
+				t.Errorf("%T: got %v, want ErrInvalidRule with %q", test.obj, err, test.msg)
+			}
+		}
+	}
+}
+
+func TestRegisterValidatorAfterUse(t *testing.T) {
+	type Code struct {
+		Code string `validate:"evencode"`
+	}
+	defer func() {
+		validatorMu.Lock()
+		delete(validators, "evencode")
+		validatorMu.Unlock()
+		rulesCache.Range(func(key, _ interface{}) bool {
+			rulesCache.Delete(key)
+			return true
+		})
+	}()
+	if err := Validate(&Code{"ab"}); !errors.Is(err, ErrInvalidRule) {
+		t.Fatalf("got %v, want ErrInvalidRule", err)
+	}
+	RegisterValidator("evencode", func(v reflect.Value, _ string) bool {
+		return len(v.String())%2 == 0
+	})
+	if err := Validate(&Code{"ab"}); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+	if err := Validate(&Code{"abc"}); err == nil {
+		t.Error("got nil, want ValidationErrors")
+	}
+}
+
+func TestBindInvalidRule(t *testing.T) {
+	type Form struct {
+		Name string `json:"name" validate:"requird"`
+	}
+	cc := New()
+	cc.POST("/users", func(resp *Response, req *Request) {
+		var form Form
+		if err := req.Bind(&form); err != nil {
+			resp.BindError(err)
+			return
+		}
+		resp.String(http.StatusOK, "ok")
+	})
+	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"a"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	cc.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}